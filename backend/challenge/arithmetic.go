@@ -0,0 +1,193 @@
+package challenge
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// 5x7 点阵字形，每行低 5 位有效
+var glyphs = map[rune][7]uint8{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'+': {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'=': {0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00},
+	'?': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+}
+
+const (
+	captchaWidth  = 180
+	captchaHeight = 60
+	captchaScale  = 4
+)
+
+// ArithmeticCaptcha 自托管的算术图形验证码
+type ArithmeticCaptcha struct {
+	TTL   time.Duration
+	store *store
+}
+
+func NewArithmeticCaptcha(ttl time.Duration) *ArithmeticCaptcha {
+	return &ArithmeticCaptcha{TTL: ttl, store: newStore()}
+}
+
+func (a *ArithmeticCaptcha) Name() string { return "captcha" }
+
+func randInt(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0
+	}
+	return int(v.Int64())
+}
+
+func (a *ArithmeticCaptcha) Issue() (map[string]interface{}, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	x, y := randInt(20)+1, randInt(20)+1
+	op, answer := '+', x+y
+	if randInt(2) == 0 && x > y {
+		op, answer = '-', x-y
+	}
+	expr := fmt.Sprintf("%d%c%d=?", x, op, y)
+
+	img, err := renderCaptcha(expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.store.put(id, fmt.Sprint(answer), a.TTL); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":    id,
+		"image": "data:image/png;base64," + img,
+	}, nil
+}
+
+func (a *ArithmeticCaptcha) Verify(_ context.Context, resp Response, _ string) error {
+	if resp.ID == "" || resp.Answer == "" {
+		return ErrMissing
+	}
+	answer, err := a.store.take(resp.ID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(resp.Answer) != answer {
+		return ErrFailed
+	}
+	return nil
+}
+
+// renderCaptcha 绘制带噪点与干扰线的表达式图片，返回 base64 编码的 PNG
+func renderCaptcha(expr string) (string, error) {
+	img := image.NewRGBA(image.Rect(0, 0, captchaWidth, captchaHeight))
+	bg := color.RGBA{uint8(220 + randInt(30)), uint8(220 + randInt(30)), uint8(220 + randInt(30)), 255}
+	for y := 0; y < captchaHeight; y++ {
+		for x := 0; x < captchaWidth; x++ {
+			img.Set(x, y, bg)
+		}
+	}
+
+	// 噪点
+	for i := 0; i < captchaWidth*captchaHeight/8; i++ {
+		img.Set(randInt(captchaWidth), randInt(captchaHeight), randomColor(100, 200))
+	}
+
+	// 字符，每个字形随机纵向偏移
+	glyphWidth := 6 * captchaScale
+	x0 := (captchaWidth - glyphWidth*len(expr)) / 2
+	if x0 < 2 {
+		x0 = 2
+	}
+	for i, ch := range expr {
+		g, ok := glyphs[ch]
+		if !ok {
+			continue
+		}
+		fg := randomColor(20, 110)
+		ox := x0 + i*glyphWidth + randInt(3) - 1
+		oy := (captchaHeight-7*captchaScale)/2 + randInt(9) - 4
+		for row := 0; row < 7; row++ {
+			for col := 0; col < 5; col++ {
+				if g[row]&(1<<(4-col)) == 0 {
+					continue
+				}
+				for dy := 0; dy < captchaScale; dy++ {
+					for dx := 0; dx < captchaScale; dx++ {
+						img.Set(ox+col*captchaScale+dx, oy+row*captchaScale+dy, fg)
+					}
+				}
+			}
+		}
+	}
+
+	// 干扰线
+	for i := 0; i < 4; i++ {
+		drawLine(img, randInt(captchaWidth), randInt(captchaHeight), randInt(captchaWidth), randInt(captchaHeight), randomColor(60, 160))
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func randomColor(min, max int) color.RGBA {
+	span := max - min
+	return color.RGBA{uint8(min + randInt(span)), uint8(min + randInt(span)), uint8(min + randInt(span)), 255}
+}
+
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
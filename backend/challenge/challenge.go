@@ -0,0 +1,165 @@
+// Package challenge 提供注册/发帖使用的人机验证，内置工作量证明与算术图形验证码，
+// 并支持 hCaptcha / Turnstile 等远程校验服务。
+package challenge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 使用场景
+const (
+	PurposeRegister = "register"
+	PurposePost     = "post"
+)
+
+var (
+	ErrMissing = errors.New("缺少人机验证")
+	ErrFailed  = errors.New("人机验证未通过")
+	ErrExpired = errors.New("人机验证已过期，请刷新")
+	ErrBusy    = errors.New("待完成的人机验证过多，请稍后再试")
+)
+
+const (
+	// maxStoreEntries 单个提供方同时保留的未完成挑战上限，防止刷接口耗尽内存
+	maxStoreEntries = 50_000
+	// storeSweepInterval 过期挑战的清理间隔
+	storeSweepInterval = time.Minute
+)
+
+// Response 客户端提交的验证结果
+type Response struct {
+	ID     string `json:"id"`
+	Answer string `json:"answer"`
+	Token  string `json:"token"`
+}
+
+// Provider 人机验证提供方
+type Provider interface {
+	Name() string
+	// Issue 生成一次挑战，返回下发给前端的参数
+	Issue() (map[string]interface{}, error)
+	// Verify 校验客户端提交的结果，remoteIP 供远程服务参考
+	Verify(ctx context.Context, resp Response, remoteIP string) error
+}
+
+// Manager 按站点设置决定启用哪些提供方
+type Manager struct {
+	providers []Provider
+	// enabled 判断提供方在某场景下是否启用，通常读取站点设置
+	enabled func(name, purpose string) bool
+}
+
+func NewManager(enabled func(name, purpose string) bool, providers ...Provider) *Manager {
+	return &Manager{providers: providers, enabled: enabled}
+}
+
+// Enabled 返回在该场景下启用的提供方
+func (m *Manager) Enabled(purpose string) []Provider {
+	var result []Provider
+	for _, p := range m.providers {
+		if m.enabled(p.Name(), purpose) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Issued 一次下发的挑战
+type Issued struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// IssueAll 为该场景下所有启用的提供方生成挑战
+func (m *Manager) IssueAll(purpose string) ([]Issued, error) {
+	result := []Issued{}
+	for _, p := range m.Enabled(purpose) {
+		params, err := p.Issue()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Name(), err)
+		}
+		result = append(result, Issued{Name: p.Name(), Params: params})
+	}
+	return result, nil
+}
+
+// VerifyAll 所有启用的提供方都必须通过
+func (m *Manager) VerifyAll(ctx context.Context, purpose string, responses map[string]Response, remoteIP string) error {
+	for _, p := range m.Enabled(purpose) {
+		resp, ok := responses[p.Name()]
+		if !ok {
+			return ErrMissing
+		}
+		if err := p.Verify(ctx, resp, remoteIP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store 一次性挑战的内存存储，校验后即删除防止重放
+type store struct {
+	mu      sync.Mutex
+	entries map[string]storeEntry
+}
+
+type storeEntry struct {
+	answer  string
+	expires time.Time
+}
+
+func newStore() *store {
+	s := &store{entries: map[string]storeEntry{}}
+	go s.sweepLoop()
+	return s
+}
+
+func (s *store) sweepLoop() {
+	ticker := time.NewTicker(storeSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.sweep()
+	}
+}
+
+// sweep 清理过期项
+func (s *store) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// put 保存挑战答案，存储已满时返回 ErrBusy
+func (s *store) put(id, answer string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= maxStoreEntries {
+		return ErrBusy
+	}
+	s.entries[id] = storeEntry{answer: answer, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// take 取出并删除
+func (s *store) take(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return "", ErrExpired
+	}
+	delete(s.entries, id)
+	if time.Now().After(e.expires) {
+		return "", ErrExpired
+	}
+	return e.answer, nil
+}
@@ -0,0 +1,94 @@
+package challenge
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProofOfWork 要求客户端找到 nonce，使 sha256(salt + nonce) 的前导零比特数不少于 difficulty
+type ProofOfWork struct {
+	// Difficulty 返回当前难度，允许从站点设置动态读取
+	Difficulty func() int
+	TTL        time.Duration
+	store      *store
+}
+
+func NewProofOfWork(difficulty func() int, ttl time.Duration) *ProofOfWork {
+	return &ProofOfWork{Difficulty: difficulty, TTL: ttl, store: newStore()}
+}
+
+func (p *ProofOfWork) Name() string { return "pow" }
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (p *ProofOfWork) Issue() (map[string]interface{}, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	difficulty := p.Difficulty()
+	if difficulty < 1 {
+		difficulty = 1
+	}
+	if difficulty > 32 {
+		difficulty = 32
+	}
+	if err := p.store.put(id, fmt.Sprintf("%s:%d", salt, difficulty), p.TTL); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":         id,
+		"salt":       salt,
+		"difficulty": difficulty,
+		"algorithm":  "sha256",
+	}, nil
+}
+
+// leadingZeroBits 计算摘要的前导零比特数
+func leadingZeroBits(sum []byte) int {
+	n := 0
+	for _, b := range sum {
+		if b == 0 {
+			n += 8
+			continue
+		}
+		n += bits.LeadingZeros8(b)
+		break
+	}
+	return n
+}
+
+func (p *ProofOfWork) Verify(_ context.Context, resp Response, _ string) error {
+	if resp.ID == "" || resp.Answer == "" {
+		return ErrMissing
+	}
+	stored, err := p.store.take(resp.ID)
+	if err != nil {
+		return err
+	}
+	salt, d, _ := strings.Cut(stored, ":")
+	difficulty, _ := strconv.Atoi(d)
+
+	sum := sha256.Sum256([]byte(salt + resp.Answer))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return ErrFailed
+	}
+	return nil
+}
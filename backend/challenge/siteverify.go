@@ -0,0 +1,88 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 官方校验地址，测试时可指向本地桩服务
+const (
+	HCaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+	TurnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+// SiteVerify 兼容 hCaptcha / Turnstile siteverify 协议的远程校验
+type SiteVerify struct {
+	ProviderName string
+	SiteKey      string
+	Secret       string
+	VerifyURL    string
+	Client       *http.Client
+}
+
+func NewHCaptcha(siteKey, secret, verifyURL string) *SiteVerify {
+	if verifyURL == "" {
+		verifyURL = HCaptchaVerifyURL
+	}
+	return &SiteVerify{ProviderName: "hcaptcha", SiteKey: siteKey, Secret: secret, VerifyURL: verifyURL}
+}
+
+func NewTurnstile(siteKey, secret, verifyURL string) *SiteVerify {
+	if verifyURL == "" {
+		verifyURL = TurnstileVerifyURL
+	}
+	return &SiteVerify{ProviderName: "turnstile", SiteKey: siteKey, Secret: secret, VerifyURL: verifyURL}
+}
+
+func (s *SiteVerify) Name() string { return s.ProviderName }
+
+func (s *SiteVerify) Issue() (map[string]interface{}, error) {
+	return map[string]interface{}{"site_key": s.SiteKey}, nil
+}
+
+func (s *SiteVerify) Verify(ctx context.Context, resp Response, remoteIP string) error {
+	if resp.Token == "" {
+		return ErrMissing
+	}
+
+	form := url.Values{}
+	form.Set("secret", s.Secret)
+	form.Set("response", resp.Token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	if s.SiteKey != "" {
+		form.Set("sitekey", s.SiteKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.VerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return ErrFailed
+	}
+	return nil
+}
@@ -3,11 +3,32 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// OAuthProvider 单个 OIDC / OAuth2 登录提供方
+type OAuthProvider struct {
+	Name         string
+	Label        string
+	ClientID     string
+	ClientSecret string
+	// Issuer 非空时通过 /.well-known/openid-configuration 自动发现端点
+	Issuer      string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	Scopes      []string
+	// 用户信息字段映射，AvatarClaim 支持 {claim} 模板
+	SubjectClaim  string
+	UsernameClaim string
+	EmailClaim    string
+	AvatarClaim   string
+}
+
 type Config struct {
 	DBHost     string
 	DBPort     string
@@ -20,6 +41,61 @@ type Config struct {
 	MCServer   string
 	MCPort     string
 	StaticDir  string
+
+	// Cookie 会话模式：登录时写入 HttpOnly Cookie，变更请求需携带 CSRF 令牌
+	CookieAuth     bool
+	CookieSecure   bool
+	CookieSameSite string
+	CookieDomain   string
+
+	// 允许跨域访问的来源，为空时不启用 CORS
+	CORSAllowedOrigins []string
+
+	// 游戏内插件回调使用的共享密钥
+	PluginSecret string
+	LinkCodeTTL  time.Duration
+
+	// argon2id 密码哈希参数，调整后旧哈希会在登录时自动升级
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+
+	// 密码策略
+	PasswordMinLength     int
+	PasswordMinClasses    int
+	BreachedPasswordsPath string
+
+	// 第三方登录，回调地址为 OAuthRedirectBase + /api/auth/oauth/<name>/callback
+	OAuthRedirectBase string
+	OAuthProviders    []OAuthProvider
+
+	// 远程人机验证服务，未配置密钥时不启用
+	HCaptchaSiteKey    string
+	HCaptchaSecret     string
+	HCaptchaVerifyURL  string
+	TurnstileSiteKey   string
+	TurnstileSecret    string
+	TurnstileVerifyURL string
+
+	// 附件存储：local 保存到本地目录，s3 使用 S3 兼容的对象存储
+	StorageDriver   string
+	StorageLocalDir string
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3PathStyle     bool
+
+	// 邮件：file 写入本地目录（开发用），smtp 通过 SMTP 发送，留空不发送邮件
+	SiteURL      string
+	MailDriver   string
+	MailFrom     string
+	MailFileDir  string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
 }
 
 func Load() *Config {
@@ -32,6 +108,17 @@ func Load() *Config {
 		expiry = 72 * time.Hour
 	}
 
+	linkTTL, err := time.ParseDuration(getEnv("MC_LINK_CODE_TTL", "10m"))
+	if err != nil {
+		linkTTL = 10 * time.Minute
+	}
+
+	// argon2 的并行度参数为 uint8，超出范围会被截断成意外的值
+	argonThreads, err := strconv.Atoi(getEnv("ARGON2_THREADS", "2"))
+	if err != nil || argonThreads < 1 || argonThreads > 255 {
+		log.Fatalf("ARGON2_THREADS must be an integer between 1 and 255, got %q", getEnv("ARGON2_THREADS", "2"))
+	}
+
 	return &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "3306"),
@@ -44,7 +131,104 @@ func Load() *Config {
 		MCServer:   getEnv("MC_SERVER", "play.hxzd.com"),
 		MCPort:     getEnv("MC_PORT", "25565"),
 		StaticDir:  getEnv("STATIC_DIR", "../"),
+
+		CookieAuth:     getEnv("AUTH_COOKIE_MODE", "false") == "true",
+		CookieSecure:   getEnv("COOKIE_SECURE", "false") == "true",
+		CookieSameSite: getEnv("COOKIE_SAMESITE", "lax"),
+		CookieDomain:   getEnv("COOKIE_DOMAIN", ""),
+
+		CORSAllowedOrigins: splitList(getEnv("CORS_ALLOWED_ORIGINS", "")),
+
+		PluginSecret: getEnv("MC_PLUGIN_SECRET", ""),
+		LinkCodeTTL:  linkTTL,
+
+		Argon2Time:    uint32(getEnvInt("ARGON2_TIME", 3)),
+		Argon2Memory:  uint32(getEnvInt("ARGON2_MEMORY", 64*1024)),
+		Argon2Threads: uint8(argonThreads),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinClasses:    getEnvInt("PASSWORD_MIN_CLASSES", 2),
+		BreachedPasswordsPath: getEnv("BREACHED_PASSWORDS_PATH", ""),
+
+		OAuthRedirectBase: strings.TrimSuffix(getEnv("OAUTH_REDIRECT_BASE", "http://localhost:8080"), "/"),
+		OAuthProviders:    loadOAuthProviders(),
+
+		HCaptchaSiteKey:    getEnv("HCAPTCHA_SITE_KEY", ""),
+		HCaptchaSecret:     getEnv("HCAPTCHA_SECRET", ""),
+		HCaptchaVerifyURL:  getEnv("HCAPTCHA_VERIFY_URL", ""),
+		TurnstileSiteKey:   getEnv("TURNSTILE_SITE_KEY", ""),
+		TurnstileSecret:    getEnv("TURNSTILE_SECRET", ""),
+		TurnstileVerifyURL: getEnv("TURNSTILE_VERIFY_URL", ""),
+
+		StorageDriver:   getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalDir: getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:     getEnv("S3_PATH_STYLE", "true") == "true",
+
+		SiteURL:      strings.TrimSuffix(getEnv("SITE_URL", "http://localhost:8080"), "/"),
+		MailDriver:   getEnv("MAIL_DRIVER", ""),
+		MailFrom:     getEnv("MAIL_FROM", "HXZD <noreply@localhost>"),
+		MailFileDir:  getEnv("MAIL_FILE_DIR", "./mail"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+	}
+}
+
+// loadOAuthProviders 读取 OAUTH_PROVIDERS=a,b 以及对应的 OAUTH_<NAME>_* 变量
+func loadOAuthProviders() []OAuthProvider {
+	var result []OAuthProvider
+	for _, name := range strings.Split(getEnv("OAUTH_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		p := OAuthProvider{
+			Name:          name,
+			Label:         getEnv(prefix+"LABEL", name),
+			ClientID:      getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:  getEnv(prefix+"CLIENT_SECRET", ""),
+			Issuer:        strings.TrimSuffix(getEnv(prefix+"ISSUER", ""), "/"),
+			AuthURL:       getEnv(prefix+"AUTH_URL", ""),
+			TokenURL:      getEnv(prefix+"TOKEN_URL", ""),
+			UserInfoURL:   getEnv(prefix+"USERINFO_URL", ""),
+			Scopes:        strings.Fields(getEnv(prefix+"SCOPES", "openid profile email")),
+			SubjectClaim:  getEnv(prefix+"SUBJECT_CLAIM", "sub"),
+			UsernameClaim: getEnv(prefix+"USERNAME_CLAIM", "preferred_username"),
+			EmailClaim:    getEnv(prefix+"EMAIL_CLAIM", "email"),
+			AvatarClaim:   getEnv(prefix+"AVATAR_CLAIM", "picture"),
+		}
+		if p.ClientID == "" {
+			log.Printf("OAuth provider %s skipped: missing %sCLIENT_ID", name, prefix)
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil || v <= 0 {
+		return fallback
 	}
+	return v
 }
 
 func getEnv(key, fallback string) string {
@@ -6,10 +6,11 @@ import (
 	"time"
 
 	"hxzd-server/config"
+	"hxzd-server/markdown"
 	"hxzd-server/models"
+	"hxzd-server/utils"
 
 	mysqldriver "github.com/go-sql-driver/mysql"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -49,38 +50,209 @@ func InitDB(cfg *config.Config) *gorm.DB {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// 帖子标签使用自定义的关联表
+	if err := db.SetupJoinTable(&models.ForumPost{}, "Tags", &models.ForumPostTag{}); err != nil {
+		log.Fatalf("Failed to setup join table: %v", err)
+	}
+
 	// 自动迁移
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Announcement{},
 		&models.ForumPost{},
+		&models.ForumPostRevision{},
 		&models.ForumComment{},
+		&models.ForumCategory{},
+		&models.ForumReaction{},
+		&models.ForumPoll{},
+		&models.ForumTag{},
+		&models.ForumPostTag{},
+		&models.ForumPollOption{},
+		&models.ForumPollVote{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.NotificationDigest{},
+		&models.Subscription{},
 		&models.SiteSetting{},
+		&models.SchemaMigration{},
 		&models.Page{},
 		&models.ServerStatusConfig{},
 		&models.GameServer{},
 		&models.WorldMap{},
+		&models.MinecraftLinkCode{},
+		&models.Role{},
+		&models.APIKey{},
+		&models.UserSanction{},
+		&models.ContentReport{},
+		&models.Attachment{},
+		&models.AuditLog{},
+		&models.UserIdentity{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	ensureFulltextIndexes(db)
+
 	log.Println("Database connected and migrated successfully")
 	return db
 }
 
-func SeedDefaults(db *gorm.DB) {
+// ensureFulltextIndexes 为站内搜索创建 FULLTEXT 索引，使用 ngram 分词以支持中文
+func ensureFulltextIndexes(db *gorm.DB) {
+	if db.Dialector.Name() != "mysql" {
+		return
+	}
+	indexes := []struct{ table, name, columns string }{
+		{"forum_posts", "ft_forum_posts", "title, content"},
+		{"forum_comments", "ft_forum_comments", "content"},
+		{"announcements", "ft_announcements", "title, content"},
+		{"pages", "ft_pages", "title, content"},
+	}
+	for _, idx := range indexes {
+		var count int64
+		db.Raw("SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+			idx.table, idx.name).Scan(&count)
+		if count > 0 {
+			continue
+		}
+		sql := fmt.Sprintf("ALTER TABLE `%s` ADD FULLTEXT INDEX `%s` (%s) WITH PARSER ngram", idx.table, idx.name, idx.columns)
+		if err := db.Exec(sql).Error; err != nil {
+			log.Printf("Failed to create fulltext index %s: %v", idx.name, err)
+		}
+	}
+}
+
+// runOnce 执行一次性数据迁移，成功后记入 schema_migrations，之后启动时跳过
+func runOnce(db *gorm.DB, name string, fn func(db *gorm.DB) error) {
+	var count int64
+	db.Model(&models.SchemaMigration{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return
+	}
+	if err := fn(db); err != nil {
+		log.Printf("Migration %s failed: %v", name, err)
+		return
+	}
+	db.Create(&models.SchemaMigration{Name: name})
+	log.Printf("Applied migration %s", name)
+}
+
+// migrateForumCategories 创建默认分类；把旧帖子里的自由文本分类转成分类记录并重算计数只在首次升级时执行
+func migrateForumCategories(db *gorm.DB) {
+	var count int64
+	db.Model(&models.ForumCategory{}).Count(&count)
+	if count == 0 {
+		defaults := []models.ForumCategory{
+			{Slug: "general", Name: "综合", Description: "综合讨论区", Icon: "💬", SortOrder: 10},
+			{Slug: "discussion", Name: "讨论", Description: "游戏话题讨论", Icon: "🗨", SortOrder: 20},
+			{Slug: "question", Name: "求助", Description: "遇到问题来这里提问", Icon: "❓", SortOrder: 30},
+			{Slug: "showcase", Name: "展示", Description: "建筑与作品展示", Icon: "🏰", SortOrder: 40},
+			{Slug: "suggestion", Name: "建议", Description: "对服务器的意见与建议", Icon: "💡", SortOrder: 50},
+			{Slug: "whitelist", Name: "白名单申请", Description: "申请加入服务器白名单", Icon: "📝", SortOrder: 60},
+		}
+		db.Create(&defaults)
+	}
+
+	runOnce(db, "forum_categories_v1", convertLegacyCategories)
+}
+
+func convertLegacyCategories(db *gorm.DB) error {
+	// 未填写分类的旧帖子归入综合
+	db.Model(&models.ForumPost{}).Where("category = '' OR category IS NULL").Update("category", "general")
+
+	var legacy []string
+	db.Model(&models.ForumPost{}).Distinct().Pluck("category", &legacy)
+	for _, slug := range legacy {
+		var existing models.ForumCategory
+		if db.Where("slug = ?", slug).First(&existing).RowsAffected == 0 {
+			db.Create(&models.ForumCategory{Slug: slug, Name: slug, SortOrder: 100})
+			log.Printf("Created forum category %q from existing posts", slug)
+		}
+	}
+
+	var categories []models.ForumCategory
+	db.Find(&categories)
+	for _, cat := range categories {
+		var posts struct {
+			Total int64
+			Last  *time.Time
+		}
+		var comments struct {
+			Last *time.Time
+		}
+		db.Model(&models.ForumPost{}).Where("category = ?", cat.Slug).
+			Select("COUNT(*) AS total, MAX(created_at) AS last").Scan(&posts)
+		db.Model(&models.ForumComment{}).
+			Joins("JOIN forum_posts ON forum_posts.id = forum_comments.post_id").
+			Where("forum_posts.category = ?", cat.Slug).
+			Select("MAX(forum_comments.created_at) AS last").Scan(&comments)
+
+		last := posts.Last
+		if comments.Last != nil && (last == nil || comments.Last.After(*last)) {
+			last = comments.Last
+		}
+		if err := db.Model(&cat).UpdateColumns(map[string]interface{}{
+			"post_count":       posts.Total,
+			"last_activity_at": last,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillForumCounters 按实际数据校正帖子的评论数与回应数，只在计数字段上线时执行一次
+func backfillForumCounters(db *gorm.DB) error {
+	err := db.Exec(`UPDATE forum_posts SET
+		comment_count = (SELECT COUNT(*) FROM forum_comments WHERE forum_comments.post_id = forum_posts.id AND forum_comments.is_deleted = false),
+		reaction_count = (SELECT COUNT(*) FROM forum_reactions WHERE forum_reactions.target_type = 'post' AND forum_reactions.target_id = forum_posts.id)`).Error
+	if err != nil {
+		return err
+	}
+	// 旧帖子的最后活跃时间取最新一条评论或发帖时间
+	return db.Exec(`UPDATE forum_posts SET last_activity_at = COALESCE(
+		(SELECT MAX(created_at) FROM forum_comments WHERE forum_comments.post_id = forum_posts.id AND forum_comments.is_deleted = false),
+		created_at) WHERE last_activity_at IS NULL`).Error
+}
+
+func SeedDefaults(db *gorm.DB, hasher utils.PasswordHasher) {
 	// 默认管理员
 	var count int64
 	db.Model(&models.User{}).Count(&count)
 	if count == 0 {
-		hash, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+		hash, _ := hasher.Hash("admin123")
 		db.Create(&models.User{
-			Username: "admin",
-			Password: string(hash),
-			Role:     "admin",
-			Email:    "admin@hxzd.com",
+			Username:           "admin",
+			Password:           hash,
+			Role:               "admin",
+			Email:              "admin@hxzd.com",
+			MustChangePassword: true,
 		})
 		log.Println("Created default admin user (admin / admin123)")
+	} else {
+		// 旧部署中仍在使用默认密码的管理员
+		var admin models.User
+		if db.Where("username = ?", "admin").First(&admin).Error == nil && !admin.MustChangePassword {
+			if ok, _ := hasher.Verify("admin123", admin.Password); ok {
+				db.Model(&admin).UpdateColumn("must_change_password", true)
+				log.Println("WARNING: admin account still uses the default password")
+			}
+		}
+	}
+
+	// 内置角色
+	roles := []models.Role{
+		{Name: "admin", Label: "管理员", Description: "拥有全部权限", Permissions: []string{utils.PermAll}, IsSystem: true},
+		{Name: "user", Label: "用户", Description: "普通注册用户", Permissions: []string{}, IsSystem: true},
+		{Name: "moderator", Label: "版主", Description: "论坛版务", Permissions: []string{utils.PermForumModerate, utils.PermReportsHandle}},
+		{Name: "editor", Label: "编辑", Description: "公告与页面编辑", Permissions: []string{utils.PermAnnouncementsManage, utils.PermPagesManage}},
+		{Name: "operator", Label: "服务器运维", Description: "游戏服务器与地图维护", Permissions: []string{utils.PermServersManage, utils.PermMapsManage}},
+	}
+	for _, r := range roles {
+		var existing models.Role
+		if db.Where("name = ?", r.Name).First(&existing).RowsAffected == 0 {
+			db.Create(&r)
+		}
 	}
 
 	// 默认设置
@@ -92,6 +264,40 @@ func SeedDefaults(db *gorm.DB) {
 		"background_url":   "",
 		"favicon_url":      "",
 		"footer_text":      "",
+
+		"audit_retention_days": "180",
+
+		// 论坛可用的表情回应，逗号分隔
+		"forum_reactions": "👍,❤️,😂,😮,😢,🎉",
+
+		// 帖子或评论收到多少条待处理举报后自动隐藏，0 表示不自动隐藏
+		"report_auto_hide_threshold": "5",
+
+		// 同一用户（访客按 IP）重复浏览同一帖子时，多少分钟内只计一次浏览量
+		"forum_view_window_minutes": "30",
+
+		// 论坛标签：每个帖子的标签数上限；关闭自由标签后普通用户只能使用已有标签
+		"forum_tag_max_per_post": "5",
+		"forum_tag_free_form":    "true",
+
+		// 论坛回收站保留天数，过期后彻底删除，0 表示永久保留
+		"forum_trash_retention_days": "30",
+
+		// 附件：单个文件大小上限与每位用户的总配额（MB，0 表示不限），未被引用的附件保留小时数
+		"attachment_max_size_mb":   "10",
+		"attachment_user_quota_mb": "200",
+		"attachment_orphan_hours":  "24",
+
+		// 人机验证开关：challenge_<提供方>_<场景>
+		"challenge_pow_register":       "true",
+		"challenge_pow_post":           "false",
+		"challenge_pow_difficulty":     "16",
+		"challenge_captcha_register":   "false",
+		"challenge_captcha_post":       "false",
+		"challenge_hcaptcha_register":  "false",
+		"challenge_hcaptcha_post":      "false",
+		"challenge_turnstile_register": "false",
+		"challenge_turnstile_post":     "false",
 	}
 	for k, v := range defaults {
 		var existing models.SiteSetting
@@ -100,6 +306,9 @@ func SeedDefaults(db *gorm.DB) {
 		}
 	}
 
+	migrateForumCategories(db)
+	runOnce(db, "forum_counters_v1", backfillForumCounters)
+
 	// 默认页面
 	pages := []models.Page{
 		{Slug: "gameplay", Title: "玩法介绍", Content: "<h2>玩法介绍</h2><p>欢迎来到花夏之都！这里有丰富的生存玩法等你探索。</p>"},
@@ -146,4 +355,37 @@ func SeedDefaults(db *gorm.DB) {
 			Enabled:   true,
 		})
 	}
+
+	rerenderContent(db)
+}
+
+// rerenderContent 重新渲染渲染器版本落后的内容（含升级前未渲染过的旧数据）
+func rerenderContent(db *gorm.DB) {
+	for _, table := range []string{"announcements", "forum_posts", "forum_comments", "pages"} {
+		var lastID uint
+		updated := 0
+		for {
+			var rows []struct {
+				ID      uint
+				Content string
+			}
+			db.Table(table).Select("id, content").
+				Where("render_version < ? AND id > ?", markdown.Version, lastID).
+				Order("id ASC").Limit(200).Scan(&rows)
+			if len(rows) == 0 {
+				break
+			}
+			for _, row := range rows {
+				db.Table(table).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{
+					"content_html":   markdown.Render(row.Content),
+					"render_version": markdown.Version,
+				})
+				lastID = row.ID
+			}
+			updated += len(rows)
+		}
+		if updated > 0 {
+			log.Printf("Re-rendered %d rows in %s (renderer v%d)", updated, table, markdown.Version)
+		}
+	}
 }
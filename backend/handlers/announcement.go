@@ -4,18 +4,22 @@ import (
 	"net/http"
 	"strconv"
 
+	"hxzd-server/markdown"
 	"hxzd-server/models"
+	"hxzd-server/notify"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type AnnouncementHandler struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Notifier *notify.Notifier
 }
 
-func NewAnnouncementHandler(db *gorm.DB) *AnnouncementHandler {
-	return &AnnouncementHandler{DB: db}
+func NewAnnouncementHandler(db *gorm.DB, notifier *notify.Notifier) *AnnouncementHandler {
+	return &AnnouncementHandler{DB: db, Notifier: notifier}
 }
 
 func (h *AnnouncementHandler) List(c *gin.Context) {
@@ -54,16 +58,32 @@ func (h *AnnouncementHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
+	if contentTooLong(c, req.Content, maxContentBytes) {
+		return
+	}
 
 	userID, _ := c.Get("user_id")
 	item := models.Announcement{
-		Title:    req.Title,
-		Content:  req.Content,
-		AuthorID: userID.(uint),
-		IsPinned: req.IsPinned,
+		Title:         req.Title,
+		Content:       req.Content,
+		ContentHTML:   markdown.Render(req.Content),
+		RenderVersion: markdown.Version,
+		AuthorID:      userID.(uint),
+		IsPinned:      req.IsPinned,
 	}
 	h.DB.Create(&item)
+	syncAttachments(h.DB, attachmentTargetAnnouncement, item.ID, item.Content, item.AuthorID)
 	h.DB.Preload("Author").First(&item, item.ID)
+	utils.RecordAudit(h.DB, c, "announcement.create", "announcement", item.ID, nil, item)
+
+	// 全站广播可能较慢，放到后台执行
+	msg := actorNotification(c, notify.TypeAnnouncement)
+	msg.Title = "新公告：" + item.Title
+	msg.Body = item.Content
+	msg.Link = "/announcements"
+	msg.TargetType = "announcement"
+	msg.TargetID = item.ID
+	go h.Notifier.Broadcast(msg)
 	c.JSON(http.StatusOK, item)
 }
 
@@ -90,22 +110,39 @@ func (h *AnnouncementHandler) Update(c *gin.Context) {
 		updates["title"] = *req.Title
 	}
 	if req.Content != nil {
+		if contentTooLong(c, *req.Content, maxContentBytes) {
+			return
+		}
 		updates["content"] = *req.Content
+		updates["content_html"] = markdown.Render(*req.Content)
+		updates["render_version"] = markdown.Version
 	}
 	if req.IsPinned != nil {
 		updates["is_pinned"] = *req.IsPinned
 	}
 
+	before := item
 	h.DB.Model(&item).Updates(updates)
+	if req.Content != nil {
+		syncAttachments(h.DB, attachmentTargetAnnouncement, item.ID, *req.Content, c.GetUint("user_id"), item.AuthorID)
+	}
 	h.DB.Preload("Author").First(&item, item.ID)
+	utils.RecordAudit(h.DB, c, "announcement.update", "announcement", item.ID, before, item)
 	c.JSON(http.StatusOK, item)
 }
 
 func (h *AnnouncementHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.DB.Delete(&models.Announcement{}, id).Error; err != nil {
+	var item models.Announcement
+	if err := h.DB.First(&item, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "公告不存在"})
+		return
+	}
+	if err := h.DB.Delete(&item).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
+	releaseAttachments(h.DB, attachmentTargetAnnouncement, item.ID)
+	utils.RecordAudit(h.DB, c, "announcement.delete", "announcement", item.ID, item, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
 }
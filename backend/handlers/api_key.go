@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	DB    *gorm.DB
+	Perms *utils.PermissionStore
+}
+
+func NewAPIKeyHandler(db *gorm.DB, perms *utils.PermissionStore) *APIKeyHandler {
+	return &APIKeyHandler{DB: db, Perms: perms}
+}
+
+// ListKeys 管理员 — API 密钥列表（不含明文）
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	var keys []models.APIKey
+	h.DB.Preload("Owner").Order("created_at DESC").Find(&keys)
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateKey 管理员 — 新建密钥，明文只在此响应中返回一次
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	var req struct {
+		Name      string     `json:"name" binding:"required"`
+		Scopes    []string   `json:"scopes" binding:"required,min=1"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "过期时间必须晚于当前时间"})
+		return
+	}
+
+	// 只能授予自己拥有的权限
+	role := currentRole(c)
+	for _, s := range req.Scopes {
+		if !utils.IsValidScope(s) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未知作用域: " + s})
+			return
+		}
+		if utils.IsValidPermission(s) && !h.Perms.Has(role, s) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "不能授予自身没有的权限: " + s})
+			return
+		}
+	}
+
+	plain, prefix, hash, err := utils.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密钥生成失败"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	key := models.APIKey{
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    req.Scopes,
+		OwnerID:   userID.(uint),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := h.DB.Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+	h.DB.Preload("Owner").First(&key, key.ID)
+	utils.RecordAudit(h.DB, c, "apikey.create", "api_key", key.ID, nil, key)
+
+	c.JSON(http.StatusOK, gin.H{"key": plain, "api_key": key})
+}
+
+// DeleteKey 管理员 — 吊销密钥
+func (h *APIKeyHandler) DeleteKey(c *gin.Context) {
+	id := c.Param("id")
+	var key models.APIKey
+	if err := h.DB.First(&key, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "密钥不存在"})
+		return
+	}
+	h.DB.Delete(&key)
+	utils.RecordAudit(h.DB, c, "apikey.delete", "api_key", key.ID, key, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "已吊销"})
+}
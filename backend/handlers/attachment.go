@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"hxzd-server/media"
+	"hxzd-server/models"
+	"hxzd-server/storage"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 附件可以关联的内容类型
+const (
+	attachmentTargetPost         = "forum_post"
+	attachmentTargetComment      = "forum_comment"
+	attachmentTargetAnnouncement = "announcement"
+	attachmentTargetPage         = "page"
+)
+
+// uploadPathPrefix 附件的访问路径前缀，内容中引用附件即写入该路径
+const uploadPathPrefix = "/uploads/"
+
+// attachmentKeyPattern 匹配内容中引用的附件（含缩略图）存储键
+var attachmentKeyPattern = regexp.MustCompile(`/uploads/(\d{4}/\d{2}/[0-9a-f]{32}(?:_thumb)?\.[a-z]+)`)
+
+var errQuotaExceeded = errors.New("attachment quota exceeded")
+
+// attachmentUsage 用户已上传附件的总大小
+func attachmentUsage(db *gorm.DB, userID uint) int64 {
+	var used int64
+	db.Model(&models.Attachment{}).Where("uploader_id = ?", userID).Select("COALESCE(SUM(size), 0)").Scan(&used)
+	return used
+}
+
+type AttachmentHandler struct {
+	DB      *gorm.DB
+	Perms   *utils.PermissionStore
+	Storage storage.Storage
+}
+
+func NewAttachmentHandler(db *gorm.DB, perms *utils.PermissionStore, store storage.Storage) *AttachmentHandler {
+	h := &AttachmentHandler{DB: db, Perms: perms, Storage: store}
+	go h.gcLoop()
+	return h
+}
+
+// withURLs 填充附件与缩略图的访问地址
+func withURLs(a *models.Attachment) {
+	a.URL = uploadPathPrefix + a.StorageKey
+	if a.ThumbKey != "" {
+		a.ThumbURL = uploadPathPrefix + a.ThumbKey
+	}
+}
+
+// settingMB 读取以 MB 为单位的设置并换算为字节，0 表示不限
+func settingMB(db *gorm.DB, key, fallback string) int64 {
+	mb, err := strconv.ParseInt(utils.GetSetting(db, key, fallback), 10, 64)
+	if err != nil || mb < 0 {
+		mb, _ = strconv.ParseInt(fallback, 10, 64)
+	}
+	return mb << 20
+}
+
+// cleanFilename 只保留原始文件名的基本名，去掉控制字符并限制长度
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	for utf8.RuneCountInString(name) > 200 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// newStorageKey 生成按年月分目录的随机存储键
+func newStorageKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return time.Now().Format("2006/01/") + hex.EncodeToString(buf), nil
+}
+
+// Upload 上传图片或文件（multipart 字段 file）；图片会清除 EXIF 并生成缩略图
+func (h *AttachmentHandler) Upload(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if s := utils.ActiveSanction(h.DB, userID, utils.SanctionMute); s != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "你已被禁言", "sanction": utils.SanctionInfo(s)})
+		return
+	}
+
+	maxSize := settingMB(h.DB, "attachment_max_size_mb", "10")
+	if maxSize > 0 {
+		// 额外留出 multipart 头部的空间
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件不能超过 %d MB", maxSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择要上传的文件"})
+		return
+	}
+	defer file.Close()
+	if maxSize > 0 && header.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件不能超过 %d MB", maxSize>>20)})
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件为空"})
+		return
+	}
+
+	// 以文件内容判断类型，忽略客户端声明的类型与扩展名
+	mimeType, ext, err := media.Sniff(data)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "不支持的文件类型，仅支持 JPG、PNG、GIF、WebP 图片以及 PDF、ZIP、TXT 文件"})
+		return
+	}
+	result, err := media.Process(data, mimeType)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "图片尺寸或动画帧数过大"})
+		return
+	case errors.Is(err, media.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "图片已损坏或无法识别"})
+		return
+	case err != nil:
+		log.Printf("[attachment] process upload failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "图片处理失败"})
+		return
+	}
+
+	// 先粗略检查配额，避免无谓地写入存储；写入记录时再在行锁下复核
+	quota := settingMB(h.DB, "attachment_user_quota_mb", "200")
+	if quota > 0 && attachmentUsage(h.DB, userID)+int64(len(result.Data)) > quota {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("附件空间已用完（%d MB），请删除不用的附件后再上传", quota>>20)})
+		return
+	}
+
+	base, err := newStorageKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "文件保存失败"})
+		return
+	}
+	attachment := models.Attachment{
+		UploaderID: userID,
+		StorageKey: base + ext,
+		Filename:   cleanFilename(header.Filename),
+		MimeType:   mimeType,
+		Size:       int64(len(result.Data)),
+		Width:      result.Width,
+		Height:     result.Height,
+	}
+	ctx := c.Request.Context()
+	if err := h.Storage.Put(ctx, attachment.StorageKey, result.Data, mimeType); err != nil {
+		log.Printf("[attachment] store %s failed: %v", attachment.StorageKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "文件保存失败"})
+		return
+	}
+	if result.Thumb != nil {
+		thumbKey := base + "_thumb" + result.ThumbExt
+		if err := h.Storage.Put(ctx, thumbKey, result.Thumb, result.ThumbType); err != nil {
+			// 缩略图失败不影响原图，前端会回退到原图
+			log.Printf("[attachment] store %s failed: %v", thumbKey, err)
+		} else {
+			attachment.ThumbKey, attachment.ThumbType = thumbKey, result.ThumbType
+		}
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if quota > 0 {
+			// 锁住上传者的用户行，同一用户的并发上传依次校验配额
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+				return err
+			}
+			if attachmentUsage(tx, userID)+attachment.Size > quota {
+				return errQuotaExceeded
+			}
+		}
+		return tx.Create(&attachment).Error
+	})
+	if err != nil {
+		h.removeObjects(&attachment)
+		if errors.Is(err, errQuotaExceeded) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("附件空间已用完（%d MB），请删除不用的附件后再上传", quota>>20)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "文件保存失败"})
+		return
+	}
+	withURLs(&attachment)
+	c.JSON(http.StatusOK, attachment)
+}
+
+// List 当前用户上传的附件与配额使用情况，unused=true 时只返回尚未被引用的附件
+func (h *AttachmentHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	query := h.DB.Model(&models.Attachment{}).Where("uploader_id = ?", userID)
+	if c.Query("unused") == "true" {
+		query = query.Where("target_type = ''")
+	}
+	var total int64
+	query.Count(&total)
+
+	var items []models.Attachment
+	query.Order("created_at DESC").Offset((page - 1) * size).Limit(size).Find(&items)
+	for i := range items {
+		withURLs(&items[i])
+	}
+
+	used := attachmentUsage(h.DB, userID)
+	c.JSON(http.StatusOK, gin.H{
+		"attachments": items,
+		"total":       total,
+		"page":        page,
+		"size":        size,
+		"used":        used,
+		"quota":       settingMB(h.DB, "attachment_user_quota_mb", "200"),
+		"max_size":    settingMB(h.DB, "attachment_max_size_mb", "10"),
+	})
+}
+
+// Delete 删除自己上传的附件，版主可删除任何人的附件；引用它的内容中图片将无法显示
+func (h *AttachmentHandler) Delete(c *gin.Context) {
+	var attachment models.Attachment
+	if err := h.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	isModerator := h.Perms.Has(currentRole(c), utils.PermForumModerate)
+	if attachment.UploaderID != userID && !isModerator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+		return
+	}
+
+	h.removeObjects(&attachment)
+	h.DB.Delete(&attachment)
+	if attachment.UploaderID != userID {
+		utils.RecordAudit(h.DB, c, "attachment.delete", "attachment", attachment.ID, attachment, nil)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
+}
+
+// Serve 读取附件内容；非图片一律以下载方式返回，避免在站点域名下被当作页面渲染
+func (h *AttachmentHandler) Serve(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	var attachment models.Attachment
+	if key == "" || h.DB.Where("storage_key = ? OR thumb_key = ?", key, key).First(&attachment).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+
+	contentType, length := attachment.MimeType, attachment.Size
+	if key == attachment.ThumbKey {
+		contentType, length = attachment.ThumbType, -1
+	}
+	body, err := h.Storage.Get(c.Request.Context(), key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("[attachment] read %s failed: %v", key, err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在"})
+		return
+	}
+	defer body.Close()
+
+	disposition := "inline"
+	if !media.IsImage(contentType) {
+		disposition = "attachment"
+	}
+	c.DataFromReader(http.StatusOK, length, contentType, body, map[string]string{
+		"Content-Disposition":     mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}),
+		"Cache-Control":           "public, max-age=31536000, immutable",
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+	})
+}
+
+// removeObjects 从存储中删除附件及其缩略图
+func (h *AttachmentHandler) removeObjects(a *models.Attachment) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, key := range []string{a.StorageKey, a.ThumbKey} {
+		if key == "" {
+			continue
+		}
+		if err := h.Storage.Delete(ctx, key); err != nil {
+			log.Printf("[attachment] delete %s failed: %v", key, err)
+		}
+	}
+}
+
+func (h *AttachmentHandler) gcLoop() {
+	h.collectOrphans()
+	// 保留期以小时计，按小时检查
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.collectOrphans()
+	}
+}
+
+// collectOrphans 删除超过 attachment_orphan_hours 仍未被任何内容引用的附件
+func (h *AttachmentHandler) collectOrphans() {
+	hours, err := strconv.Atoi(utils.GetSetting(h.DB, "attachment_orphan_hours", "24"))
+	if err != nil || hours <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	removed := 0
+	for {
+		var batch []models.Attachment
+		h.DB.Where("target_type = '' AND updated_at < ?", cutoff).Order("id").Limit(200).Find(&batch)
+		for i := range batch {
+			h.removeObjects(&batch[i])
+			h.DB.Delete(&batch[i])
+		}
+		removed += len(batch)
+		if len(batch) < 200 {
+			break
+		}
+	}
+	if removed > 0 {
+		log.Printf("[attachment] collected %d orphaned attachments older than %d hours", removed, hours)
+	}
+}
+
+// referencedAttachmentKeys 提取内容中引用的附件存储键（去重）
+func referencedAttachmentKeys(contents ...string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, content := range contents {
+		for _, m := range attachmentKeyPattern.FindAllStringSubmatch(content, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				keys = append(keys, m[1])
+			}
+		}
+	}
+	return keys
+}
+
+// syncAttachments 内容保存后调用：把内容中引用、且由 uploaderIDs 上传的未关联附件关联到目标，
+// 目标不再引用的附件解除关联，等待回收
+func syncAttachments(db *gorm.DB, targetType string, targetID uint, content string, uploaderIDs ...uint) {
+	keys := referencedAttachmentKeys(content)
+	release := db.Model(&models.Attachment{}).Where("target_type = ? AND target_id = ?", targetType, targetID)
+	if len(keys) > 0 {
+		release = release.Where("storage_key NOT IN ? AND thumb_key NOT IN ?", keys, keys)
+	}
+	release.Updates(map[string]interface{}{"target_type": "", "target_id": 0})
+
+	if len(keys) == 0 || len(uploaderIDs) == 0 {
+		return
+	}
+	db.Model(&models.Attachment{}).
+		Where("storage_key IN ? OR thumb_key IN ?", keys, keys).
+		Where("target_type = '' AND uploader_id IN ?", uploaderIDs).
+		Updates(map[string]interface{}{"target_type": targetType, "target_id": targetID})
+}
+
+// releaseAttachments 内容被彻底删除时解除其附件的关联，等待回收
+func releaseAttachments(db *gorm.DB, targetType string, ids ...uint) {
+	if len(ids) == 0 {
+		return
+	}
+	db.Model(&models.Attachment{}).
+		Where("target_type = ? AND target_id IN ?", targetType, ids).
+		Updates(map[string]interface{}{"target_type": "", "target_id": 0})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AuditHandler struct {
+	DB *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	h := &AuditHandler{DB: db}
+	go h.purgeLoop()
+	return h
+}
+
+func (h *AuditHandler) purgeLoop() {
+	h.purgeExpired()
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.purgeExpired()
+	}
+}
+
+// purgeExpired 按 audit_retention_days 设置清理过期记录，0 表示永久保留
+func (h *AuditHandler) purgeExpired() {
+	days, err := strconv.Atoi(utils.GetSetting(h.DB, "audit_retention_days", "0"))
+	if err != nil || days <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	result := h.DB.Where("created_at < ?", cutoff).Delete(&models.AuditLog{})
+	if result.RowsAffected > 0 {
+		log.Printf("[audit] purged %d entries older than %d days", result.RowsAffected, days)
+	}
+}
+
+// ListLogs 管理员 — 审计日志，支持 actor_id / action / target_type / target_id / from / to 过滤
+func (h *AuditHandler) ListLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 200 {
+		size = 50
+	}
+
+	query := h.DB.Model(&models.AuditLog{})
+	if v := c.Query("actor_id"); v != "" {
+		query = query.Where("actor_id = ?", v)
+	}
+	if v := c.Query("action"); v != "" {
+		query = query.Where("action = ?", v)
+	}
+	if v := c.Query("target_type"); v != "" {
+		query = query.Where("target_type = ?", v)
+	}
+	if v := c.Query("target_id"); v != "" {
+		query = query.Where("target_id = ?", v)
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from 时间格式错误"})
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to 时间格式错误"})
+			return
+		}
+		query = query.Where("created_at <= ?", t)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var logs []models.AuditLog
+	query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&logs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":  logs,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
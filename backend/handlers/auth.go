@@ -3,43 +3,59 @@ package handlers
 import (
 	"net/http"
 
+	"hxzd-server/challenge"
 	"hxzd-server/config"
 	"hxzd-server/models"
 	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	DB  *gorm.DB
-	Cfg *config.Config
+	DB         *gorm.DB
+	Cfg        *config.Config
+	Perms      *utils.PermissionStore
+	Challenges *challenge.Manager
+	Hasher     utils.PasswordHasher
+	Policy     *utils.PasswordPolicy
 }
 
-func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
-	return &AuthHandler{DB: db, Cfg: cfg}
+func NewAuthHandler(db *gorm.DB, cfg *config.Config, perms *utils.PermissionStore, challenges *challenge.Manager, hasher utils.PasswordHasher, policy *utils.PasswordPolicy) *AuthHandler {
+	return &AuthHandler{DB: db, Cfg: cfg, Perms: perms, Challenges: challenges, Hasher: hasher, Policy: policy}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req struct {
 		Username    string `json:"username" binding:"required,min=2,max=32"`
-		Password    string `json:"password" binding:"required,min=6"`
+		Password    string `json:"password" binding:"required"`
 		Email       string `json:"email"`
 		MinecraftID string `json:"minecraft_id"`
+
+		Challenge map[string]challenge.Response `json:"challenge"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
 		return
 	}
+	if !verifyChallenge(c, h.Challenges, challenge.PurposeRegister, req.Challenge) {
+		return
+	}
 
 	var existing models.User
 	if h.DB.Where("username = ?", req.Username).First(&existing).Error == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "用户名已被使用"})
 		return
 	}
+	if minecraftNameTaken(c, h.DB, req.MinecraftID, 0) {
+		return
+	}
+	if err := h.Policy.Check(req.Password, req.Username, req.MinecraftID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hash, err := h.Hasher.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
 		return
@@ -47,7 +63,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	user := models.User{
 		Username:    req.Username,
-		Password:    string(hash),
+		Password:    hash,
 		Email:       req.Email,
 		MinecraftID: req.MinecraftID,
 		Role:        "user",
@@ -57,8 +73,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	token, _ := utils.GenerateToken(user.ID, user.Username, user.Role, h.Cfg.JWTSecret, h.Cfg.JWTExpiry)
-	c.JSON(http.StatusOK, gin.H{"token": token, "user": user})
+	user.Permissions = h.Perms.Permissions(user.Role)
+	h.respondWithSession(c, &user)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -77,15 +93,52 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	ok, rehash := h.Hasher.Verify(req.Password, user.Password)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
 		return
 	}
+	// 旧算法或旧参数的哈希在登录成功时透明升级
+	if rehash {
+		if hash, err := h.Hasher.Hash(req.Password); err == nil {
+			h.DB.Model(&user).UpdateColumn("password", hash)
+		}
+	}
+
+	if s := utils.ActiveSanction(h.DB, user.ID, utils.SanctionBan); s != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "账号已被封禁", "sanction": utils.SanctionInfo(s)})
+		return
+	}
 
-	token, _ := utils.GenerateToken(user.ID, user.Username, user.Role, h.Cfg.JWTSecret, h.Cfg.JWTExpiry)
+	user.Permissions = h.Perms.Permissions(user.Role)
+	user.PasswordChangeRequired = &user.MustChangePassword
+	h.respondWithSession(c, &user)
+}
+
+// respondWithSession 签发令牌；Cookie 会话模式下令牌只写入 HttpOnly Cookie，不返回给前端脚本
+func (h *AuthHandler) respondWithSession(c *gin.Context, user *models.User) {
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, h.Cfg.JWTSecret, h.Cfg.JWTExpiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+	if h.Cfg.CookieAuth {
+		if err := utils.SetSessionCookies(c, h.Cfg, token); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"token": "", "user": user})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"token": token, "user": user})
 }
 
+// Logout 清除会话 Cookie；令牌模式下由前端自行丢弃令牌
+func (h *AuthHandler) Logout(c *gin.Context) {
+	utils.ClearSessionCookies(c, h.Cfg)
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
 func (h *AuthHandler) Me(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	var user models.User
@@ -93,6 +146,11 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 		return
 	}
+	user.Permissions = h.Perms.Permissions(user.Role)
+	user.Sanctions = utils.ActiveSanctions(h.DB, user.ID)
+	user.PasswordChangeRequired = &user.MustChangePassword
+	unread := unreadCount(h.DB, user.ID)
+	user.UnreadNotifications = &unread
 	c.JSON(http.StatusOK, user)
 }
 
@@ -117,6 +175,16 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 		updates["avatar_url"] = *req.AvatarURL
 	}
 	if req.MinecraftID != nil {
+		// 已通过游戏内验证的账号，玩家名只能由插件回调更新
+		var current models.User
+		h.DB.First(&current, userID)
+		if current.MinecraftUUID != nil && *req.MinecraftID != current.MinecraftID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "已绑定 Minecraft 账号，请先解除绑定"})
+			return
+		}
+		if minecraftNameTaken(c, h.DB, *req.MinecraftID, current.ID) {
+			return
+		}
 		updates["minecraft_id"] = *req.MinecraftID
 	}
 	if req.Username != nil && *req.Username != "" {
@@ -142,8 +210,8 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	var req struct {
-		OldPassword string `json:"old_password" binding:"required"`
-		NewPassword string `json:"new_password" binding:"required,min=6"`
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
@@ -153,12 +221,25 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	var user models.User
 	h.DB.First(&user, userID)
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
+	// 通过第三方登录创建的账号尚未设置密码，无需旧密码
+	if ok, _ := h.Hasher.Verify(req.OldPassword, user.Password); user.Password != "" && !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "旧密码错误"})
 		return
 	}
 
-	hash, _ := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
-	h.DB.Model(&user).Update("password", string(hash))
+	if err := h.Policy.Check(req.NewPassword, user.Username, user.MinecraftID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := h.Hasher.Hash(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		return
+	}
+	h.DB.Model(&user).Updates(map[string]interface{}{
+		"password":             hash,
+		"must_change_password": false,
+	})
 	c.JSON(http.StatusOK, gin.H{"message": "密码已更新"})
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"hxzd-server/challenge"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// challengeIssueLimit 每个 IP 在一个窗口内最多获取的挑战次数
+	challengeIssueLimit  = 30
+	challengeIssueWindow = time.Minute
+)
+
+type ChallengeHandler struct {
+	Challenges *challenge.Manager
+
+	mu     sync.Mutex
+	issued map[string]*issueWindow
+}
+
+// issueWindow 某个 IP 在当前固定窗口内的获取次数
+type issueWindow struct {
+	start time.Time
+	count int
+}
+
+func NewChallengeHandler(challenges *challenge.Manager) *ChallengeHandler {
+	h := &ChallengeHandler{Challenges: challenges, issued: map[string]*issueWindow{}}
+	go h.sweepLoop()
+	return h
+}
+
+// allowIssue 按 IP 限制获取频率
+func (h *ChallengeHandler) allowIssue(ip string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	w := h.issued[ip]
+	if w == nil || now.Sub(w.start) >= challengeIssueWindow {
+		h.issued[ip] = &issueWindow{start: now, count: 1}
+		return true
+	}
+	if w.count >= challengeIssueLimit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+func (h *ChallengeHandler) sweepLoop() {
+	ticker := time.NewTicker(challengeIssueWindow)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		h.mu.Lock()
+		for ip, w := range h.issued {
+			if now.Sub(w.start) >= challengeIssueWindow {
+				delete(h.issued, ip)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Issue 公开接口 — 下发指定场景需要完成的人机验证
+func (h *ChallengeHandler) Issue(c *gin.Context) {
+	purpose := c.DefaultQuery("purpose", challenge.PurposeRegister)
+	if purpose != challenge.PurposeRegister && purpose != challenge.PurposePost {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未知场景"})
+		return
+	}
+	if !h.allowIssue(c.ClientIP()) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+		return
+	}
+	issued, err := h.Challenges.IssueAll(purpose)
+	if errors.Is(err, challenge.ErrBusy) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成人机验证失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"challenges": issued})
+}
+
+// verifyChallenge 校验请求携带的人机验证结果，失败时已写入响应
+func verifyChallenge(c *gin.Context, m *challenge.Manager, purpose string, responses map[string]challenge.Response) bool {
+	err := m.VerifyAll(c.Request.Context(), purpose, responses, c.ClientIP())
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, challenge.ErrMissing) || errors.Is(err, challenge.ErrFailed) || errors.Is(err, challenge.ErrExpired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "challenge_required": true})
+		return false
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": "人机验证服务不可用"})
+	return false
+}
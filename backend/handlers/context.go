@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 正文长度上限（字节）。帖子、评论与公告存放在 TEXT 列中；页面为 LONGTEXT，但同样需要限制渲染开销
+const (
+	maxContentBytes     = 65535
+	maxPageContentBytes = 1 << 20
+)
+
+// currentRole 读取认证中间件写入的角色名
+func currentRole(c *gin.Context) string {
+	role, _ := c.Get("role")
+	s, _ := role.(string)
+	return s
+}
+
+// contentTooLong 正文超过 limit 字节时写入 400 响应，应在渲染 Markdown 之前调用
+func contentTooLong(c *gin.Context, content string, limit int) bool {
+	if len(content) <= limit {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("内容过长，不能超过 %d KB", limit>>10)})
+	return true
+}
@@ -3,25 +3,111 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"hxzd-server/challenge"
+	"hxzd-server/markdown"
 	"hxzd-server/models"
+	"hxzd-server/notify"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type ForumHandler struct {
-	DB *gorm.DB
+	DB         *gorm.DB
+	Perms      *utils.PermissionStore
+	Challenges *challenge.Manager
+	Notifier   *notify.Notifier
+
+	views *viewCounter
+}
+
+func NewForumHandler(db *gorm.DB, perms *utils.PermissionStore, challenges *challenge.Manager, notifier *notify.Notifier) *ForumHandler {
+	return &ForumHandler{DB: db, Perms: perms, Challenges: challenges, Notifier: notifier, views: newViewCounter(db)}
+}
+
+func (h *ForumHandler) isModerator(c *gin.Context) bool {
+	return h.Perms.Has(currentRole(c), utils.PermForumModerate)
+}
+
+// rejectMuted 被禁言的用户只能浏览，不能发帖或评论
+func (h *ForumHandler) rejectMuted(c *gin.Context) bool {
+	s := utils.ActiveSanction(h.DB, c.GetUint("user_id"), utils.SanctionMute)
+	if s == nil {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "你已被禁言", "sanction": utils.SanctionInfo(s)})
+	return true
+}
+
+// findCategory 按 slug 查找分类，不存在时写入 400 响应
+func (h *ForumHandler) findCategory(c *gin.Context, slug string) (*models.ForumCategory, bool) {
+	var cat models.ForumCategory
+	if slug == "" || h.DB.Where("slug = ?", slug).First(&cat).Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分类不存在"})
+		return nil, false
+	}
+	return &cat, true
 }
 
-func NewForumHandler(db *gorm.DB) *ForumHandler {
-	return &ForumHandler{DB: db}
+// readableCategory 帖子所在分类对当前用户不可见时写入 403 响应
+func (h *ForumHandler) readableCategory(c *gin.Context, slug string) bool {
+	var cat models.ForumCategory
+	if h.DB.Where("slug = ?", slug).First(&cat).Error == nil && !canReadCategory(h.Perms, currentRole(c), &cat) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限查看该分类"})
+		return false
+	}
+	return true
+}
+
+// deleteReason 读取删除原因（?reason=），过长时写入 400 响应
+func deleteReason(c *gin.Context) (string, bool) {
+	reason := strings.TrimSpace(c.Query("reason"))
+	if utf8.RuneCountInString(reason) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "删除原因过长"})
+		return "", false
+	}
+	return reason, true
+}
+
+// moderationBody 版务通知正文，有处理原因时优先展示原因
+func moderationBody(reason, content string) string {
+	if reason != "" {
+		return "原因：" + reason
+	}
+	return content
+}
+
+// canSeeHidden 被隐藏的内容只有作者与版主可见
+func (h *ForumHandler) canSeeHidden(c *gin.Context, authorID uint) bool {
+	return authorID == c.GetUint("user_id") || h.isModerator(c)
+}
+
+// viewablePost 帖子所在分类不可见或帖子已被隐藏时写入错误响应
+func (h *ForumHandler) viewablePost(c *gin.Context, post *models.ForumPost) bool {
+	if !h.readableCategory(c, post.Category) {
+		return false
+	}
+	if post.IsHidden && !h.canSeeHidden(c, post.AuthorID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
+		return false
+	}
+	return true
 }
 
+// ListPosts 帖子列表。latest / activity 排序支持游标分页：传入上一页返回的 next_cursor 即可继续翻页，
+// 新帖不会导致重复或遗漏；count=false 时跳过总数统计。筛选：author（用户名）/ author_id、
+// from / to（发帖日期，含当天）、has_replies=true|false
 func (h *ForumHandler) ListPosts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
 	category := c.Query("category")
+	sort := c.DefaultQuery("sort", "latest")
+	cursorParam := c.Query("cursor")
 
 	if page < 1 {
 		page = 1
@@ -32,70 +118,260 @@ func (h *ForumHandler) ListPosts(c *gin.Context) {
 
 	query := h.DB.Model(&models.ForumPost{})
 	if category != "" {
+		if !h.readableCategory(c, category) {
+			return
+		}
 		query = query.Where("category = ?", category)
+	} else if hidden := hiddenCategories(h.DB, h.Perms, currentRole(c)); len(hidden) > 0 {
+		query = query.Where("category NOT IN ?", hidden)
+	}
+	if !h.isModerator(c) {
+		query = query.Where("is_hidden = ? OR author_id = ?", false, c.GetUint("user_id"))
+	}
+
+	// 筛选条件
+	if authorID := c.Query("author_id"); authorID != "" {
+		query = query.Where("author_id = ?", authorID)
+	} else if author := strings.TrimSpace(c.Query("author")); author != "" {
+		query = query.Where("author_id IN (?)", h.DB.Model(&models.User{}).Select("id").Where("username = ?", author))
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateParam(from, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式错误，应为 YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateParam(to, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式错误，应为 YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("created_at < ?", t)
+	}
+	// tags=a,b；tag_mode=all 时需同时带有全部标签，默认带有任一标签即可
+	if names := parseTagQuery(c.Query("tags")); len(names) > 0 {
+		tagged := h.DB.Model(&models.ForumPostTag{}).Select("forum_post_tags.post_id").
+			Joins("JOIN forum_tags ON forum_tags.id = forum_post_tags.tag_id").
+			Where("forum_tags.name IN ?", names)
+		if c.Query("tag_mode") == "all" {
+			tagged = tagged.Group("forum_post_tags.post_id").Having("COUNT(*) = ?", len(names))
+		}
+		query = query.Where("id IN (?)", tagged)
+	}
+	switch c.Query("has_replies") {
+	case "true":
+		query = query.Where("comment_count > 0")
+	case "false":
+		query = query.Where("comment_count = 0")
 	}
 
-	var total int64
-	query.Count(&total)
+	result := gin.H{"size": size}
+	if c.Query("count") != "false" {
+		var total int64
+		query.Count(&total)
+		result["total"] = total
+	}
+
+	var order string
+	column, keyset := postSortColumn[sort]
+	switch sort {
+	case "hot":
+		// 回应与评论按发帖时长衰减
+		order = "is_pinned DESC, (reaction_count + comment_count * 2 + 1) / POW(TIMESTAMPDIFF(HOUR, created_at, NOW()) + 2, 1.5) DESC, created_at DESC, id DESC"
+	case "top":
+		order = "is_pinned DESC, reaction_count + comment_count DESC, created_at DESC, id DESC"
+	default:
+		if !keyset {
+			column, keyset = "created_at", true
+		}
+		order = "is_pinned DESC, " + column + " DESC, id DESC"
+	}
 
+	if cursorParam != "" {
+		if !keyset {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "该排序不支持游标分页"})
+			return
+		}
+		cur, err := decodePostCursor(cursorParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "分页游标无效"})
+			return
+		}
+		// 按 (is_pinned, 时间列, id) 降序取游标之后的记录
+		query = query.Where("is_pinned < ? OR (is_pinned = ? AND ("+column+" < ? OR ("+column+" = ? AND id < ?)))",
+			cur.Pinned, cur.Pinned, cur.Time, cur.Time, cur.ID)
+	} else {
+		query = query.Offset((page - 1) * size)
+		result["page"] = page
+	}
+
+	// 多取一条判断是否还有下一页
 	var posts []models.ForumPost
-	query.Preload("Author").
-		Order("is_pinned DESC, created_at DESC").
-		Offset((page - 1) * size).
-		Limit(size).
+	query.Preload("Author").Preload("Tags").
+		Order(order).
+		Limit(size + 1).
 		Find(&posts)
+	hasMore := len(posts) > size
+	if hasMore {
+		posts = posts[:size]
+	}
+	h.attachPostReactions(c, posts)
 
-	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
-		"total": total,
-		"page":  page,
-		"size":  size,
-	})
+	result["posts"] = posts
+	result["has_more"] = hasMore
+	if hasMore && keyset {
+		result["next_cursor"] = encodePostCursor(&posts[len(posts)-1], column)
+	}
+	c.JSON(http.StatusOK, result)
 }
 
 func (h *ForumHandler) GetPost(c *gin.Context) {
 	id := c.Param("id")
 	var post models.ForumPost
 	if err := h.DB.Preload("Author").Preload("Comments", func(db *gorm.DB) *gorm.DB {
-		return db.Order("created_at ASC")
-	}).Preload("Comments.Author").First(&post, id).Error; err != nil {
+		return db.Order("created_at ASC, id ASC")
+	}).Preload("Comments.Author").Preload("Comments.ReplyToUser").
+		Preload("Comments.Quote").Preload("Comments.Quote.Author").
+		Preload("Poll").Preload("Poll.Options", preloadPollOptions).Preload("Tags").
+		First(&post, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
 		return
 	}
+	if !h.viewablePost(c, &post) {
+		return
+	}
+
+	post.Reactions = h.reactionCounts(reactionTargetPost, []uint{post.ID}, c.GetUint("user_id"))[post.ID]
+	post.Comments = pruneDeletedComments(post.Comments)
+	h.attachCommentReactions(c, post.Comments)
+	mask := func(cm *models.ForumComment) {
+		maskDeletedComment(cm)
+		if cm.IsHidden && !h.canSeeHidden(c, cm.AuthorID) {
+			maskHiddenComment(cm)
+		}
+	}
+	for i := range post.Comments {
+		mask(&post.Comments[i])
+		if post.Comments[i].Quote != nil {
+			mask(post.Comments[i].Quote)
+		}
+	}
+	// view=tree 返回嵌套结构，默认按时间平铺
+	if c.Query("view") == "tree" {
+		post.Comments = buildCommentTree(post.Comments)
+	}
 
-	// 增加浏览量
-	h.DB.Model(&post).UpdateColumn("view_count", gorm.Expr("view_count + 1"))
+	// 浏览量异步批量写回，返回时加上尚未写回的部分
+	h.views.Record(c, &post)
+	post.ViewCount += h.views.Pending(post.ID)
+	post.Watching = isWatching(h.DB, c.GetUint("user_id"), subscriptionTargetPost, post.ID)
+	if post.Poll != nil {
+		h.preparePoll(post.Poll, c.GetUint("user_id"))
+	}
 
 	c.JSON(http.StatusOK, post)
 }
 
 func (h *ForumHandler) CreatePost(c *gin.Context) {
+	if h.rejectMuted(c) {
+		return
+	}
+
 	var req struct {
 		Title    string `json:"title" binding:"required"`
 		Content  string `json:"content" binding:"required"`
-		Category string `json:"category"`
+		Category string `json:"category" binding:"required"`
+
+		Tags      []string                      `json:"tags"`
+		Poll      *pollRequest                  `json:"poll"`
+		Challenge map[string]challenge.Response `json:"challenge"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
+	if contentTooLong(c, req.Content, maxContentBytes) {
+		return
+	}
+	cat, ok := h.findCategory(c, req.Category)
+	if !ok {
+		return
+	}
+	if !canPostCategory(h.Perms, currentRole(c), cat) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限在该分类发帖"})
+		return
+	}
+	tagNames, ok := h.resolveTags(c, req.Tags)
+	if !ok {
+		return
+	}
+	var poll *models.ForumPoll
+	if req.Poll != nil {
+		var msg string
+		if poll, msg = buildPoll(req.Poll, time.Now()); poll == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+	if !verifyChallenge(c, h.Challenges, challenge.PurposePost, req.Challenge) {
+		return
+	}
 
 	userID, _ := c.Get("user_id")
 	post := models.ForumPost{
-		Title:    req.Title,
-		Content:  req.Content,
-		Category: req.Category,
-		AuthorID: userID.(uint),
+		Title:          req.Title,
+		Content:        req.Content,
+		ContentHTML:    markdown.Render(req.Content),
+		RenderVersion:  markdown.Version,
+		Category:       cat.Slug,
+		AuthorID:       userID.(uint),
+		LastActivityAt: time.Now(),
+	}
+	// 新标签与帖子在同一事务中创建，发帖失败时不会留下孤立的标签
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		if poll != nil {
+			poll.PostID = post.ID
+			if err := tx.Create(poll).Error; err != nil {
+				return err
+			}
+		}
+		tags, err := ensureTags(tx, tagNames)
+		if err != nil {
+			return err
+		}
+		setPostTags(tx, post.ID, tags)
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发帖失败"})
+		return
+	}
+	touchCategory(h.DB, cat.Slug, 1)
+	syncAttachments(h.DB, attachmentTargetPost, post.ID, post.Content, post.AuthorID)
+	h.notifyMentions(c, &post, post.Content, postLink(post.ID), "post", post.ID, map[uint]bool{post.AuthorID: true})
+	h.notifyCategoryWatchers(c, cat, &post)
+	setWatching(h.DB, post.AuthorID, subscriptionTargetPost, post.ID, true, true)
+	h.DB.Preload("Author").Preload("Poll").Preload("Poll.Options", preloadPollOptions).Preload("Tags").First(&post, post.ID)
+	post.Watching = isWatching(h.DB, post.AuthorID, subscriptionTargetPost, post.ID)
+	if post.Poll != nil {
+		h.preparePoll(post.Poll, post.AuthorID)
 	}
-	h.DB.Create(&post)
-	h.DB.Preload("Author").First(&post, post.ID)
 	c.JSON(http.StatusOK, post)
 }
 
 func (h *ForumHandler) UpdatePost(c *gin.Context) {
+	if h.rejectMuted(c) {
+		return
+	}
+
 	id := c.Param("id")
 	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
 
 	var post models.ForumPost
 	if err := h.DB.First(&post, id).Error; err != nil {
@@ -103,17 +379,19 @@ func (h *ForumHandler) UpdatePost(c *gin.Context) {
 		return
 	}
 
-	// 只有作者或管理员可以编辑
-	if post.AuthorID != userID.(uint) && role != "admin" {
+	// 只有作者或版主可以编辑
+	if post.AuthorID != userID.(uint) && !h.isModerator(c) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
 		return
 	}
 
 	var req struct {
-		Title    *string `json:"title"`
-		Content  *string `json:"content"`
-		Category *string `json:"category"`
-		IsPinned *bool   `json:"is_pinned"`
+		Title    *string   `json:"title"`
+		Content  *string   `json:"content"`
+		Category *string   `json:"category"`
+		Tags     *[]string `json:"tags"`
+		IsPinned *bool     `json:"is_pinned"`
+		Reason   string    `json:"reason" binding:"max=255"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
@@ -121,28 +399,89 @@ func (h *ForumHandler) UpdatePost(c *gin.Context) {
 	}
 
 	updates := map[string]interface{}{}
-	if req.Title != nil {
-		updates["title"] = *req.Title
+	title, content := post.Title, post.Content
+	if req.Title != nil && *req.Title != post.Title {
+		title = *req.Title
+		updates["title"] = title
 	}
-	if req.Content != nil {
-		updates["content"] = *req.Content
+	if req.Content != nil && *req.Content != post.Content {
+		if contentTooLong(c, *req.Content, maxContentBytes) {
+			return
+		}
+		content = *req.Content
+		updates["content"] = content
+		updates["content_html"] = markdown.Render(content)
+		updates["render_version"] = markdown.Version
 	}
-	if req.Category != nil {
-		updates["category"] = *req.Category
+	if req.Category != nil && *req.Category != post.Category {
+		cat, ok := h.findCategory(c, *req.Category)
+		if !ok {
+			return
+		}
+		// 版主可以把帖子移动到任意分类
+		if !h.isModerator(c) && !canPostCategory(h.Perms, currentRole(c), cat) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限在该分类发帖"})
+			return
+		}
+		updates["category"] = cat.Slug
 	}
-	if req.IsPinned != nil && role == "admin" {
+	if req.IsPinned != nil && h.isModerator(c) {
 		updates["is_pinned"] = *req.IsPinned
 	}
+	var tagNames []string
+	if req.Tags != nil {
+		var ok bool
+		if tagNames, ok = h.resolveTags(c, *req.Tags); !ok {
+			return
+		}
+	}
 
-	h.DB.Model(&post).Updates(updates)
-	h.DB.Preload("Author").First(&post, post.ID)
+	// 全部校验通过后，历史版本与帖子更新在同一事务中写入
+	oldCategory := post.Category
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if title != post.Title || content != post.Content {
+			if err := recordRevision(tx, c, &post, title, content, req.Reason); err != nil {
+				return err
+			}
+			updates["edited_at"] = time.Now()
+			updates["edit_count"] = gorm.Expr("edit_count + 1")
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&post).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		slug, moved := updates["category"].(string)
+		if moved {
+			touchCategory(tx, oldCategory, -1)
+			touchCategory(tx, slug, 1)
+		}
+		if req.Tags != nil {
+			tags, err := ensureTags(tx, tagNames)
+			if err != nil {
+				return err
+			}
+			setPostTags(tx, post.ID, tags)
+		} else if moved {
+			// 移入或移出受限分类会改变标签的公开帖子数
+			refreshPostTagCounts(tx, post.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+	if _, changed := updates["content"]; changed {
+		syncAttachments(h.DB, attachmentTargetPost, post.ID, content, userID.(uint), post.AuthorID)
+	}
+	h.DB.Preload("Author").Preload("Tags").First(&post, post.ID)
 	c.JSON(http.StatusOK, post)
 }
 
 func (h *ForumHandler) DeletePost(c *gin.Context) {
 	id := c.Param("id")
 	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
 
 	var post models.ForumPost
 	if err := h.DB.First(&post, id).Error; err != nil {
@@ -150,60 +489,118 @@ func (h *ForumHandler) DeletePost(c *gin.Context) {
 		return
 	}
 
-	if post.AuthorID != userID.(uint) && role != "admin" {
+	if post.AuthorID != userID.(uint) && !h.isModerator(c) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
 		return
 	}
 
-	// 删除帖子的所有评论
-	h.DB.Where("post_id = ?", post.ID).Delete(&models.ForumComment{})
-	h.DB.Delete(&post)
+	reason, ok := deleteReason(c)
+	if !ok {
+		return
+	}
+	removePost(h.DB, &post, userID.(uint), reason)
+	if post.AuthorID != userID.(uint) {
+		utils.RecordAudit(h.DB, c, "forum_post.delete", "forum_post", post.ID, nil, gin.H{"reason": reason})
+		h.notifyModeration(c, post.AuthorID, "你的帖子《"+post.Title+"》已被版主删除", moderationBody(reason, post.Content), "")
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
 }
 
 func (h *ForumHandler) CreateComment(c *gin.Context) {
+	if h.rejectMuted(c) {
+		return
+	}
+
 	postID := c.Param("id")
 	var post models.ForumPost
 	if err := h.DB.First(&post, postID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
 		return
 	}
+	if !h.viewablePost(c, &post) {
+		return
+	}
 
 	var req struct {
-		Content string `json:"content" binding:"required"`
+		Content  string `json:"content" binding:"required"`
+		ParentID *uint  `json:"parent_id"`
+		QuoteID  *uint  `json:"quote_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
+	if contentTooLong(c, req.Content, maxContentBytes) {
+		return
+	}
 
 	userID, _ := c.Get("user_id")
 	comment := models.ForumComment{
-		PostID:   post.ID,
-		AuthorID: userID.(uint),
-		Content:  req.Content,
+		PostID:        post.ID,
+		AuthorID:      userID.(uint),
+		Content:       req.Content,
+		ContentHTML:   markdown.Render(req.Content),
+		RenderVersion: markdown.Version,
+	}
+	if req.ParentID != nil {
+		parent, ok := h.findPostComment(c, post.ID, *req.ParentID, "回复的评论不存在")
+		if !ok {
+			return
+		}
+		comment.ReplyToUserID = &parent.AuthorID
+		if parent.Depth >= maxCommentDepth {
+			// 超过最大层级时挂到同一层，靠 reply_to_user 区分回复对象
+			comment.ParentID = parent.ParentID
+			comment.Depth = parent.Depth
+		} else {
+			comment.ParentID = &parent.ID
+			comment.Depth = parent.Depth + 1
+		}
+	}
+	if req.QuoteID != nil {
+		quote, ok := h.findPostComment(c, post.ID, *req.QuoteID, "引用的评论不存在")
+		if !ok {
+			return
+		}
+		comment.QuoteID = &quote.ID
 	}
+
 	h.DB.Create(&comment)
-	h.DB.Preload("Author").First(&comment, comment.ID)
+	h.DB.Model(&post).UpdateColumns(map[string]interface{}{
+		"comment_count":    gorm.Expr("comment_count + 1"),
+		"last_activity_at": comment.CreatedAt,
+	})
+	syncAttachments(h.DB, attachmentTargetComment, comment.ID, comment.Content, comment.AuthorID)
+	touchCategory(h.DB, post.Category, 0)
+	h.notifyComment(c, &post, &comment)
+	setWatching(h.DB, comment.AuthorID, subscriptionTargetPost, post.ID, true, true)
+	h.DB.Preload("Author").Preload("ReplyToUser").Preload("Quote").Preload("Quote.Author").First(&comment, comment.ID)
 	c.JSON(http.StatusOK, comment)
 }
 
 func (h *ForumHandler) DeleteComment(c *gin.Context) {
 	commentID := c.Param("commentId")
 	userID, _ := c.Get("user_id")
-	role, _ := c.Get("role")
 
 	var comment models.ForumComment
-	if err := h.DB.First(&comment, commentID).Error; err != nil {
+	if err := h.DB.First(&comment, commentID).Error; err != nil || comment.IsDeleted {
 		c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在"})
 		return
 	}
 
-	if comment.AuthorID != userID.(uint) && role != "admin" {
+	if comment.AuthorID != userID.(uint) && !h.isModerator(c) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
 		return
 	}
 
-	h.DB.Delete(&comment)
+	reason, ok := deleteReason(c)
+	if !ok {
+		return
+	}
+	removeComment(h.DB, &comment, userID.(uint), reason)
+	if comment.AuthorID != userID.(uint) {
+		utils.RecordAudit(h.DB, c, "forum_comment.delete", "forum_comment", comment.ID, nil, gin.H{"reason": reason})
+		h.notifyModeration(c, comment.AuthorID, "你的一条评论已被版主删除", moderationBody(reason, comment.Content), postLink(comment.PostID))
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
 }
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+type ForumCategoryHandler struct {
+	DB    *gorm.DB
+	Perms *utils.PermissionStore
+}
+
+func NewForumCategoryHandler(db *gorm.DB, perms *utils.PermissionStore) *ForumCategoryHandler {
+	return &ForumCategoryHandler{DB: db, Perms: perms}
+}
+
+func canReadCategory(perms *utils.PermissionStore, role string, cat *models.ForumCategory) bool {
+	return cat.ReadPermission == "" || perms.Has(role, cat.ReadPermission)
+}
+
+func canPostCategory(perms *utils.PermissionStore, role string, cat *models.ForumCategory) bool {
+	return canReadCategory(perms, role, cat) && (cat.PostPermission == "" || perms.Has(role, cat.PostPermission))
+}
+
+// hiddenCategories 当前角色无权查看的分类 slug
+func hiddenCategories(db *gorm.DB, perms *utils.PermissionStore, role string) []string {
+	var restricted []models.ForumCategory
+	db.Where("read_permission <> ''").Find(&restricted)
+	hidden := []string{}
+	for i := range restricted {
+		if !canReadCategory(perms, role, &restricted[i]) {
+			hidden = append(hidden, restricted[i].Slug)
+		}
+	}
+	return hidden
+}
+
+// touchCategory 调整分类帖子数并刷新最后活跃时间，delta 为负（删帖）时不刷新活跃时间
+func touchCategory(db *gorm.DB, slug string, delta int) {
+	updates := map[string]interface{}{}
+	if delta != 0 {
+		updates["post_count"] = gorm.Expr("post_count + ?", delta)
+	}
+	if delta >= 0 {
+		updates["last_activity_at"] = time.Now()
+	}
+	db.Model(&models.ForumCategory{}).Where("slug = ?", slug).UpdateColumns(updates)
+}
+
+func validCategoryPermission(p string) bool {
+	return p == "" || utils.IsValidPermission(p)
+}
+
+// ListCategories 公开接口 — 当前用户可见的分类
+func (h *ForumCategoryHandler) ListCategories(c *gin.Context) {
+	var categories []models.ForumCategory
+	h.DB.Order("sort_order ASC, id ASC").Find(&categories)
+
+	role := currentRole(c)
+	userID := c.GetUint("user_id")
+	watched := map[uint]bool{}
+	if userID != 0 {
+		var ids []uint
+		h.DB.Model(&models.Subscription{}).
+			Where("user_id = ? AND target_type = ? AND watching = ?", userID, subscriptionTargetCategory, true).
+			Pluck("target_id", &ids)
+		for _, id := range ids {
+			watched[id] = true
+		}
+	}
+	visible := []models.ForumCategory{}
+	for _, cat := range categories {
+		if !canReadCategory(h.Perms, role, &cat) {
+			continue
+		}
+		cat.CanPost = userID != 0 && canPostCategory(h.Perms, role, &cat)
+		cat.Watching = watched[cat.ID]
+		visible = append(visible, cat)
+	}
+	c.JSON(http.StatusOK, visible)
+}
+
+// AdminListCategories 管理员 — 全部分类
+func (h *ForumCategoryHandler) AdminListCategories(c *gin.Context) {
+	var categories []models.ForumCategory
+	h.DB.Order("sort_order ASC, id ASC").Find(&categories)
+	c.JSON(http.StatusOK, categories)
+}
+
+// CreateCategory 管理员 — 新建分类
+func (h *ForumCategoryHandler) CreateCategory(c *gin.Context) {
+	var req struct {
+		Slug           string `json:"slug" binding:"required"`
+		Name           string `json:"name" binding:"required"`
+		Description    string `json:"description"`
+		Icon           string `json:"icon"`
+		SortOrder      int    `json:"sort_order"`
+		ReadPermission string `json:"read_permission"`
+		PostPermission string `json:"post_permission"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if !categorySlugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标识只能包含小写字母、数字和连字符"})
+		return
+	}
+	if !validCategoryPermission(req.ReadPermission) || !validCategoryPermission(req.PostPermission) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "包含未知权限"})
+		return
+	}
+
+	var existing models.ForumCategory
+	if h.DB.Where("slug = ?", req.Slug).First(&existing).Error == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "分类标识已存在"})
+		return
+	}
+
+	cat := models.ForumCategory{
+		Slug:           req.Slug,
+		Name:           req.Name,
+		Description:    req.Description,
+		Icon:           req.Icon,
+		SortOrder:      req.SortOrder,
+		ReadPermission: req.ReadPermission,
+		PostPermission: req.PostPermission,
+	}
+	h.DB.Create(&cat)
+	utils.RecordAudit(h.DB, c, "forum_category.create", "forum_category", cat.ID, nil, cat)
+	c.JSON(http.StatusOK, cat)
+}
+
+// UpdateCategory 管理员 — 修改分类，标识不可修改
+func (h *ForumCategoryHandler) UpdateCategory(c *gin.Context) {
+	id := c.Param("id")
+	var cat models.ForumCategory
+	if err := h.DB.First(&cat, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+		return
+	}
+
+	var req struct {
+		Name           *string `json:"name"`
+		Description    *string `json:"description"`
+		Icon           *string `json:"icon"`
+		SortOrder      *int    `json:"sort_order"`
+		ReadPermission *string `json:"read_permission"`
+		PostPermission *string `json:"post_permission"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "分类名称不能为空"})
+			return
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Icon != nil {
+		updates["icon"] = *req.Icon
+	}
+	if req.SortOrder != nil {
+		updates["sort_order"] = *req.SortOrder
+	}
+	if req.ReadPermission != nil {
+		if !validCategoryPermission(*req.ReadPermission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "包含未知权限"})
+			return
+		}
+		updates["read_permission"] = *req.ReadPermission
+	}
+	if req.PostPermission != nil {
+		if !validCategoryPermission(*req.PostPermission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "包含未知权限"})
+			return
+		}
+		updates["post_permission"] = *req.PostPermission
+	}
+
+	before := cat
+	h.DB.Model(&cat).Updates(updates)
+	h.DB.First(&cat, cat.ID)
+	if cat.ReadPermission != before.ReadPermission {
+		refreshCategoryTagCounts(h.DB, cat.Slug)
+	}
+	utils.RecordAudit(h.DB, c, "forum_category.update", "forum_category", cat.ID, before, cat)
+	c.JSON(http.StatusOK, cat)
+}
+
+// DeleteCategory 管理员 — 删除分类，分类下仍有帖子时需通过 move_to 指定迁移目标
+func (h *ForumCategoryHandler) DeleteCategory(c *gin.Context) {
+	id := c.Param("id")
+	var cat models.ForumCategory
+	if err := h.DB.First(&cat, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类不存在"})
+		return
+	}
+
+	// 回收站中的帖子一并迁移，恢复后仍有所属分类
+	var posts, total int64
+	h.DB.Model(&models.ForumPost{}).Where("category = ?", cat.Slug).Count(&posts)
+	h.DB.Unscoped().Model(&models.ForumPost{}).Where("category = ?", cat.Slug).Count(&total)
+	if total > 0 {
+		moveTo := c.Query("move_to")
+		var target models.ForumCategory
+		if moveTo == "" || moveTo == cat.Slug || h.DB.Where("slug = ?", moveTo).First(&target).Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "分类下仍有帖子，请指定有效的迁移目标分类"})
+			return
+		}
+		h.DB.Unscoped().Model(&models.ForumPost{}).Where("category = ?", cat.Slug).Update("category", target.Slug)
+		h.DB.Model(&target).UpdateColumn("post_count", gorm.Expr("post_count + ?", posts))
+		refreshCategoryTagCounts(h.DB, target.Slug)
+	}
+
+	h.DB.Delete(&cat)
+	deleteSubscriptions(h.DB, subscriptionTargetCategory, cat.ID)
+	utils.RecordAudit(h.DB, c, "forum_category.delete", "forum_category", cat.ID, cat, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
+}
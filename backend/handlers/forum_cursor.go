@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"hxzd-server/models"
+)
+
+// postCursor 帖子列表的游标，记录上一页最后一条的排序键 (is_pinned, 时间列, id)
+type postCursor struct {
+	Pinned bool      `json:"p"`
+	Time   time.Time `json:"t"`
+	ID     uint      `json:"id"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// postSortColumn 支持游标分页的排序对应的时间列，hot / top 的排序值随时间变化，不支持游标
+var postSortColumn = map[string]string{
+	"latest":   "created_at",
+	"activity": "last_activity_at",
+}
+
+func encodePostCursor(post *models.ForumPost, column string) string {
+	cur := postCursor{Pinned: post.IsPinned, Time: post.CreatedAt, ID: post.ID}
+	if column == "last_activity_at" {
+		cur.Time = post.LastActivityAt
+	}
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePostCursor(s string) (*postCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cur postCursor
+	if json.Unmarshal(data, &cur) != nil || cur.ID == 0 || cur.Time.IsZero() {
+		return nil, errInvalidCursor
+	}
+	return &cur, nil
+}
+
+// parseDateParam 解析日期筛选参数，支持 2006-01-02 与 RFC3339；
+// exclusiveEnd 为 true 时只写日期的参数取次日零点，作为开区间的截止时间
+func parseDateParam(s string, exclusiveEnd bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if exclusiveEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+
+	"hxzd-server/models"
+	"hxzd-server/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+func postLink(postID uint) string {
+	return fmt.Sprintf("/forum?post=%d", postID)
+}
+
+func commentLink(postID, commentID uint) string {
+	return fmt.Sprintf("/forum?post=%d#comment-%d", postID, commentID)
+}
+
+// actorNotification 以当前用户为触发者的通知模板
+func actorNotification(c *gin.Context, typ string) models.Notification {
+	actorID := c.GetUint("user_id")
+	return models.Notification{Type: typ, ActorID: &actorID, ActorName: c.GetString("username")}
+}
+
+// notifyMentions 通知内容中被 @ 的用户；notified 中的用户已收到其他通知，看不到该分类的用户不通知
+func (h *ForumHandler) notifyMentions(c *gin.Context, post *models.ForumPost, content, link, targetType string, targetID uint, notified map[uint]bool) {
+	names := notify.MentionedUsernames(content)
+	if len(names) == 0 {
+		return
+	}
+	var cat models.ForumCategory
+	h.DB.Where("slug = ?", post.Category).First(&cat)
+
+	var users []models.User
+	h.DB.Where("username IN ?", names).Find(&users)
+	for _, u := range users {
+		if notified[u.ID] || !canReadCategory(h.Perms, u.Role, &cat) {
+			continue
+		}
+		notified[u.ID] = true
+		msg := actorNotification(c, notify.TypeMention)
+		msg.UserID = u.ID
+		msg.Title = c.GetString("username") + " 在《" + post.Title + "》中提到了你"
+		msg.Body = content
+		msg.Link = link
+		msg.TargetType = targetType
+		msg.TargetID = targetID
+		h.Notifier.Send(msg)
+	}
+}
+
+// notifyComment 新评论通知帖子作者、被回复与被引用的评论作者、被 @ 的用户，以及关注该帖子的用户
+func (h *ForumHandler) notifyComment(c *gin.Context, post *models.ForumPost, comment *models.ForumComment) {
+	link := commentLink(post.ID, comment.ID)
+	notified := map[uint]bool{c.GetUint("user_id"): true}
+	send := func(userID uint, title string) {
+		if notified[userID] {
+			return
+		}
+		notified[userID] = true
+		msg := actorNotification(c, notify.TypeReply)
+		msg.UserID = userID
+		msg.Title = title
+		msg.Body = comment.Content
+		msg.Link = link
+		msg.TargetType = "comment"
+		msg.TargetID = comment.ID
+		h.Notifier.Send(msg)
+	}
+
+	username := c.GetString("username")
+	if comment.ReplyToUserID != nil {
+		send(*comment.ReplyToUserID, username+" 回复了你在《"+post.Title+"》中的评论")
+	}
+	if comment.QuoteID != nil {
+		var quoted models.ForumComment
+		if h.DB.First(&quoted, *comment.QuoteID).Error == nil {
+			send(quoted.AuthorID, username+" 引用了你在《"+post.Title+"》中的评论")
+		}
+	}
+	send(post.AuthorID, username+" 评论了你的帖子《"+post.Title+"》")
+
+	h.notifyMentions(c, post, comment.Content, link, "comment", comment.ID, notified)
+	h.notifyPostWatchers(c, post, comment, notified)
+}
+
+// notifyModeration 版主处理他人内容时通知作者
+func (h *ForumHandler) notifyModeration(c *gin.Context, userID uint, title, body, link string) {
+	if userID == c.GetUint("user_id") {
+		return
+	}
+	msg := actorNotification(c, notify.TypeModeration)
+	msg.UserID = userID
+	msg.Title = title
+	msg.Body = body
+	msg.Link = link
+	h.Notifier.Send(msg)
+}
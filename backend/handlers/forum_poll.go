@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"hxzd-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pollMinOptions   = 2
+	pollMaxOptions   = 20
+	pollOptionMaxLen = 100
+)
+
+var (
+	errAlreadyVoted = errors.New("already voted")
+	errNotVoted     = errors.New("not voted")
+)
+
+// pollRequest 发帖时附带的投票，ends_at 为空表示不限时
+type pollRequest struct {
+	Options   []string   `json:"options"`
+	Multiple  bool       `json:"multiple"`
+	Anonymous bool       `json:"anonymous"`
+	EndsAt    *time.Time `json:"ends_at"`
+}
+
+// buildPoll 校验投票参数，返回待创建的投票或错误提示；空白选项会被忽略
+func buildPoll(req *pollRequest, now time.Time) (*models.ForumPoll, string) {
+	poll := &models.ForumPoll{Multiple: req.Multiple, Anonymous: req.Anonymous, EndsAt: req.EndsAt}
+	seen := map[string]bool{}
+	for _, text := range req.Options {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if utf8.RuneCountInString(text) > pollOptionMaxLen {
+			return nil, "投票选项不能超过 100 个字"
+		}
+		if seen[text] {
+			return nil, "投票选项不能重复"
+		}
+		seen[text] = true
+		poll.Options = append(poll.Options, models.ForumPollOption{Text: text, SortOrder: len(poll.Options)})
+	}
+	if len(poll.Options) < pollMinOptions || len(poll.Options) > pollMaxOptions {
+		return nil, "投票需要 2 到 20 个选项"
+	}
+	if poll.EndsAt != nil && !poll.EndsAt.After(now) {
+		return nil, "投票截止时间必须晚于当前时间"
+	}
+	return poll, ""
+}
+
+// deletePoll 删除帖子的投票及全部投票记录
+func deletePoll(db *gorm.DB, postID uint) {
+	var poll models.ForumPoll
+	if db.Where("post_id = ?", postID).First(&poll).Error != nil {
+		return
+	}
+	db.Where("poll_id = ?", poll.ID).Delete(&models.ForumPollVote{})
+	db.Where("poll_id = ?", poll.ID).Delete(&models.ForumPollOption{})
+	db.Delete(&poll)
+}
+
+func preloadPollOptions(db *gorm.DB) *gorm.DB {
+	return db.Order("sort_order ASC, id ASC")
+}
+
+// preparePoll 填充截止状态与当前用户的选择；投票后或截止后才公开票数，公开投票同时返回每个选项的投票人
+func (h *ForumHandler) preparePoll(poll *models.ForumPoll, userID uint) {
+	poll.Closed = poll.EndsAt != nil && !time.Now().Before(*poll.EndsAt)
+	var mine []uint
+	if userID != 0 {
+		h.DB.Model(&models.ForumPollVote{}).Where("poll_id = ? AND user_id = ?", poll.ID, userID).Pluck("option_id", &mine)
+	}
+	poll.MyVotes = append([]uint{}, mine...)
+	poll.ShowResults = poll.Closed || len(poll.MyVotes) > 0
+	if !poll.ShowResults {
+		for i := range poll.Options {
+			poll.Options[i].VoteCount = 0
+		}
+		return
+	}
+	if poll.Anonymous {
+		return
+	}
+
+	var voters []struct {
+		OptionID uint
+		Username string
+	}
+	h.DB.Model(&models.ForumPollVote{}).Select("forum_poll_votes.option_id, users.username").
+		Joins("JOIN users ON users.id = forum_poll_votes.user_id").
+		Where("forum_poll_votes.poll_id = ?", poll.ID).
+		Order("forum_poll_votes.id ASC").Scan(&voters)
+	byOption := map[uint][]string{}
+	for _, v := range voters {
+		byOption[v.OptionID] = append(byOption[v.OptionID], v.Username)
+	}
+	for i := range poll.Options {
+		poll.Options[i].Voters = byOption[poll.Options[i].ID]
+	}
+}
+
+// findPoll 读取帖子的投票，帖子不可见时写入错误响应
+func (h *ForumHandler) findPoll(c *gin.Context) (*models.ForumPoll, bool) {
+	var post models.ForumPost
+	if err := h.DB.First(&post, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
+		return nil, false
+	}
+	if !h.viewablePost(c, &post) {
+		return nil, false
+	}
+	var poll models.ForumPoll
+	if err := h.DB.Preload("Options", preloadPollOptions).Where("post_id = ?", post.ID).First(&poll).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该帖子没有投票"})
+		return nil, false
+	}
+	return &poll, true
+}
+
+func (h *ForumHandler) respondPoll(c *gin.Context, pollID uint) {
+	var poll models.ForumPoll
+	h.DB.Preload("Options", preloadPollOptions).First(&poll, pollID)
+	h.preparePoll(&poll, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, poll)
+}
+
+// VotePoll 投票，提交 {"option_ids": [1, 2]}；单选只能选一项，每人只能投一次，改票需先撤销
+func (h *ForumHandler) VotePoll(c *gin.Context) {
+	if h.rejectMuted(c) {
+		return
+	}
+	poll, ok := h.findPoll(c)
+	if !ok {
+		return
+	}
+	if poll.EndsAt != nil && !time.Now().Before(*poll.EndsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "投票已截止"})
+		return
+	}
+
+	var req struct {
+		OptionIDs []uint `json:"option_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	valid := map[uint]bool{}
+	for _, o := range poll.Options {
+		valid[o.ID] = true
+	}
+	chosen := map[uint]bool{}
+	var optionIDs []uint
+	for _, id := range req.OptionIDs {
+		if !valid[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "投票选项不存在"})
+			return
+		}
+		if !chosen[id] {
+			chosen[id] = true
+			optionIDs = append(optionIDs, id)
+		}
+	}
+	if len(optionIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择投票选项"})
+		return
+	}
+	if !poll.Multiple && len(optionIDs) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该投票只能选择一项"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	// 锁住投票行，保证同一用户并发提交时只有一次生效
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var locked models.ForumPoll
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, poll.ID).Error; err != nil {
+			return err
+		}
+		var count int64
+		tx.Model(&models.ForumPollVote{}).Where("poll_id = ? AND user_id = ?", poll.ID, userID).Count(&count)
+		if count > 0 {
+			return errAlreadyVoted
+		}
+		votes := make([]models.ForumPollVote, 0, len(optionIDs))
+		for _, id := range optionIDs {
+			votes = append(votes, models.ForumPollVote{PollID: poll.ID, UserID: userID, OptionID: id})
+		}
+		if err := tx.Create(&votes).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ForumPollOption{}).Where("id IN ?", optionIDs).
+			UpdateColumn("vote_count", gorm.Expr("vote_count + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&locked).UpdateColumn("voter_count", gorm.Expr("voter_count + 1")).Error
+	})
+	if errors.Is(err, errAlreadyVoted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "你已经投过票了，如需改票请先撤销"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "投票失败"})
+		return
+	}
+	h.respondPoll(c, poll.ID)
+}
+
+// UnvotePoll 撤销自己的投票，截止后不能撤销
+func (h *ForumHandler) UnvotePoll(c *gin.Context) {
+	poll, ok := h.findPoll(c)
+	if !ok {
+		return
+	}
+	if poll.EndsAt != nil && !time.Now().Before(*poll.EndsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "投票已截止，不能撤销"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var locked models.ForumPoll
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, poll.ID).Error; err != nil {
+			return err
+		}
+		var optionIDs []uint
+		tx.Model(&models.ForumPollVote{}).Where("poll_id = ? AND user_id = ?", poll.ID, userID).Pluck("option_id", &optionIDs)
+		if len(optionIDs) == 0 {
+			return errNotVoted
+		}
+		if err := tx.Where("poll_id = ? AND user_id = ?", poll.ID, userID).Delete(&models.ForumPollVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ForumPollOption{}).Where("id IN ? AND vote_count > 0", optionIDs).
+			UpdateColumn("vote_count", gorm.Expr("vote_count - 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&locked).Where("voter_count > 0").UpdateColumn("voter_count", gorm.Expr("voter_count - 1")).Error
+	})
+	if errors.Is(err, errNotVoted) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "你还没有投票"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销失败"})
+		return
+	}
+	h.respondPoll(c, poll.ID)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	reactionTargetPost    = "post"
+	reactionTargetComment = "comment"
+)
+
+// allowedReactions 站点设置 forum_reactions 中配置的表情，按配置顺序
+func (h *ForumHandler) allowedReactions() []string {
+	var emojis []string
+	for _, e := range strings.Split(utils.GetSetting(h.DB, "forum_reactions", "👍"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			emojis = append(emojis, e)
+		}
+	}
+	return emojis
+}
+
+// reactionCounts 批量统计目标上的表情回应，按配置的表情顺序排列
+func (h *ForumHandler) reactionCounts(targetType string, ids []uint, userID uint) map[uint][]models.ReactionCount {
+	result := map[uint][]models.ReactionCount{}
+	if len(ids) == 0 {
+		return result
+	}
+	var rows []struct {
+		TargetID uint
+		Emoji    string
+		Count    int64
+		Mine     int64
+	}
+	h.DB.Model(&models.ForumReaction{}).
+		Select("target_id, emoji, COUNT(*) AS count, SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END) AS mine", userID).
+		Where("target_type = ? AND target_id IN ?", targetType, ids).
+		Group("target_id, emoji").Scan(&rows)
+
+	order := map[string]int{}
+	for i, e := range h.allowedReactions() {
+		order[e] = i + 1
+	}
+	for _, r := range rows {
+		result[r.TargetID] = append(result[r.TargetID], models.ReactionCount{Emoji: r.Emoji, Count: r.Count, Reacted: r.Mine > 0})
+	}
+	for _, counts := range result {
+		sortReactions(counts, order)
+	}
+	return result
+}
+
+// sortReactions 已下架的表情排在最后
+func sortReactions(counts []models.ReactionCount, order map[string]int) {
+	rank := func(e string) int {
+		if r, ok := order[e]; ok {
+			return r
+		}
+		return len(order) + 1
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return rank(counts[i].Emoji) < rank(counts[j].Emoji)
+	})
+}
+
+func (h *ForumHandler) attachPostReactions(c *gin.Context, posts []models.ForumPost) {
+	ids := make([]uint, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	counts := h.reactionCounts(reactionTargetPost, ids, c.GetUint("user_id"))
+	for i := range posts {
+		posts[i].Reactions = counts[posts[i].ID]
+	}
+}
+
+func (h *ForumHandler) attachCommentReactions(c *gin.Context, comments []models.ForumComment) {
+	ids := make([]uint, len(comments))
+	for i, cm := range comments {
+		ids[i] = cm.ID
+	}
+	counts := h.reactionCounts(reactionTargetComment, ids, c.GetUint("user_id"))
+	for i := range comments {
+		comments[i].Reactions = counts[comments[i].ID]
+	}
+}
+
+// reactionTarget 解析回应目标，校验帖子可见性；失败时已写入响应
+func (h *ForumHandler) reactionTarget(c *gin.Context) (string, uint, *models.ForumPost, bool) {
+	var post models.ForumPost
+	if commentID := c.Param("commentId"); commentID != "" {
+		var comment models.ForumComment
+		if err := h.DB.First(&comment, commentID).Error; err != nil || comment.IsDeleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在"})
+			return "", 0, nil, false
+		}
+		if h.DB.First(&post, comment.PostID).Error != nil || !h.viewablePost(c, &post) {
+			if !c.Writer.Written() {
+				c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
+			}
+			return "", 0, nil, false
+		}
+		return reactionTargetComment, comment.ID, &post, true
+	}
+
+	if err := h.DB.First(&post, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
+		return "", 0, nil, false
+	}
+	if !h.viewablePost(c, &post) {
+		return "", 0, nil, false
+	}
+	return reactionTargetPost, post.ID, &post, true
+}
+
+func (h *ForumHandler) respondReactions(c *gin.Context, targetType string, targetID uint) {
+	counts := h.reactionCounts(targetType, []uint{targetID}, c.GetUint("user_id"))[targetID]
+	if counts == nil {
+		counts = []models.ReactionCount{}
+	}
+	c.JSON(http.StatusOK, gin.H{"reactions": counts})
+}
+
+// AddReaction 给帖子或评论添加表情回应，重复添加视为成功
+func (h *ForumHandler) AddReaction(c *gin.Context) {
+	if h.rejectMuted(c) {
+		return
+	}
+	targetType, targetID, post, ok := h.reactionTarget(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Emoji string `json:"emoji" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	allowed := false
+	for _, e := range h.allowedReactions() {
+		if e == req.Emoji {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的表情"})
+		return
+	}
+
+	reaction := models.ForumReaction{
+		TargetType: targetType,
+		TargetID:   targetID,
+		UserID:     c.GetUint("user_id"),
+		Emoji:      req.Emoji,
+	}
+	result := h.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	if result.RowsAffected > 0 && targetType == reactionTargetPost {
+		h.DB.Model(post).UpdateColumn("reaction_count", gorm.Expr("reaction_count + 1"))
+	}
+	h.respondReactions(c, targetType, targetID)
+}
+
+// RemoveReaction 撤销自己的表情回应
+func (h *ForumHandler) RemoveReaction(c *gin.Context) {
+	targetType, targetID, post, ok := h.reactionTarget(c)
+	if !ok {
+		return
+	}
+
+	result := h.DB.Where("target_type = ? AND target_id = ? AND user_id = ? AND emoji = ?",
+		targetType, targetID, c.GetUint("user_id"), c.Query("emoji")).Delete(&models.ForumReaction{})
+	if result.RowsAffected > 0 && targetType == reactionTargetPost {
+		h.DB.Model(post).UpdateColumn("reaction_count", gorm.Expr("reaction_count - ?", result.RowsAffected))
+	}
+	h.respondReactions(c, targetType, targetID)
+}
+
+// deleteReactions 清理被删除内容上的回应
+func deleteReactions(db *gorm.DB, targetType string, ids ...uint) {
+	if len(ids) == 0 {
+		return
+	}
+	db.Where("target_type = ? AND target_id IN ?", targetType, ids).Delete(&models.ForumReaction{})
+}
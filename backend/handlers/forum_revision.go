@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hxzd-server/markdown"
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// revisionDiffContext 差异中保留的上下文行数
+const revisionDiffContext = 3
+
+// revisionView 历史版本及其相对上一版的差异
+type revisionView struct {
+	models.ForumPostRevision
+	PreviousTitle string `json:"previous_title,omitempty"`
+	Diff          string `json:"diff"`
+}
+
+// recordRevision 编辑前调用：首次编辑时先补存原始内容为第 1 版，再追加新版本；应与帖子更新在同一事务中执行
+func recordRevision(tx *gorm.DB, c *gin.Context, post *models.ForumPost, title, content, reason string) error {
+	var latest models.ForumPostRevision
+	if tx.Where("post_id = ?", post.ID).Order("version DESC").First(&latest).Error != nil {
+		latest = models.ForumPostRevision{
+			PostID:    post.ID,
+			Version:   1,
+			Title:     post.Title,
+			Content:   post.Content,
+			EditorID:  post.AuthorID,
+			CreatedAt: post.CreatedAt,
+		}
+		if err := tx.Create(&latest).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&models.ForumPostRevision{
+		PostID:   post.ID,
+		Version:  latest.Version + 1,
+		Title:    title,
+		Content:  content,
+		Reason:   reason,
+		EditorID: c.GetUint("user_id"),
+	}).Error
+}
+
+// revisionPost 读取帖子并校验只有作者与版主能查看历史；失败时已写入响应
+func (h *ForumHandler) revisionPost(c *gin.Context) (*models.ForumPost, bool) {
+	var post models.ForumPost
+	if err := h.DB.First(&post, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
+		return nil, false
+	}
+	if post.AuthorID != c.GetUint("user_id") && !h.isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+		return nil, false
+	}
+	return &post, true
+}
+
+func versionName(v int) string {
+	return fmt.Sprintf("v%d", v)
+}
+
+// ListRevisions 帖子编辑历史（新版本在前，分页），每版附带相对上一版的统一格式差异；
+// 传入 from / to 时只返回这两个版本之间的差异
+func (h *ForumHandler) ListRevisions(c *gin.Context) {
+	post, ok := h.revisionPost(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, _ := strconv.Atoi(c.Query("from"))
+		to, _ := strconv.Atoi(c.Query("to"))
+		var pair []models.ForumPostRevision
+		h.DB.Where("post_id = ? AND version IN ?", post.ID, []int{from, to}).Find(&pair)
+		var a, b *models.ForumPostRevision
+		for i := range pair {
+			if pair[i].Version == from {
+				a = &pair[i]
+			}
+			if pair[i].Version == to {
+				b = &pair[i]
+			}
+		}
+		if a == nil || b == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "版本不存在"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"from":       a.Version,
+			"to":         b.Version,
+			"from_title": a.Title,
+			"to_title":   b.Title,
+			"diff":       utils.UnifiedDiff(a.Content, b.Content, versionName(a.Version), versionName(b.Version), revisionDiffContext),
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 50 {
+		size = 10
+	}
+	query := h.DB.Model(&models.ForumPostRevision{}).Where("post_id = ?", post.ID)
+	var total int64
+	query.Count(&total)
+
+	// 多取一条作为本页最后一版的上一版，只用于计算差异
+	var revisions []models.ForumPostRevision
+	query.Preload("Editor").
+		Order("version DESC").
+		Offset((page - 1) * size).
+		Limit(size + 1).
+		Find(&revisions)
+
+	views := make([]revisionView, 0, size)
+	for i, rev := range revisions {
+		if i == size {
+			break
+		}
+		view := revisionView{ForumPostRevision: rev}
+		if i+1 < len(revisions) {
+			prev := revisions[i+1]
+			view.Diff = utils.UnifiedDiff(prev.Content, rev.Content, versionName(prev.Version), versionName(rev.Version), revisionDiffContext)
+			if prev.Title != rev.Title {
+				view.PreviousTitle = prev.Title
+			}
+		}
+		views = append(views, view)
+	}
+	c.JSON(http.StatusOK, gin.H{"post_id": post.ID, "revisions": views, "total": total, "page": page, "size": size})
+}
+
+// RestoreRevision 版主 — 把帖子恢复到某个历史版本，恢复本身也记为一次新版本
+func (h *ForumHandler) RestoreRevision(c *gin.Context) {
+	if !h.isModerator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+		return
+	}
+	post, ok := h.revisionPost(c)
+	if !ok {
+		return
+	}
+
+	var rev models.ForumPostRevision
+	if err := h.DB.Where("id = ? AND post_id = ?", c.Param("revisionId"), post.ID).First(&rev).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+		return
+	}
+	if rev.Title == post.Title && rev.Content == post.Content {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "帖子内容已与该版本一致"})
+		return
+	}
+
+	before := *post
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := recordRevision(tx, c, post, rev.Title, rev.Content, fmt.Sprintf("恢复到第 %d 版", rev.Version)); err != nil {
+			return err
+		}
+		return tx.Model(post).Updates(map[string]interface{}{
+			"title":          rev.Title,
+			"content":        rev.Content,
+			"content_html":   markdown.Render(rev.Content),
+			"render_version": markdown.Version,
+			"edited_at":      time.Now(),
+			"edit_count":     gorm.Expr("edit_count + 1"),
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复失败"})
+		return
+	}
+	syncAttachments(h.DB, attachmentTargetPost, post.ID, rev.Content, c.GetUint("user_id"), post.AuthorID)
+	h.DB.Preload("Author").First(post, post.ID)
+	utils.RecordAudit(h.DB, c, "forum_post.restore", "forum_post", post.ID, before, post)
+	h.notifyModeration(c, post.AuthorID, fmt.Sprintf("你的帖子《%s》已被版主恢复到第 %d 版", post.Title, rev.Version), "", postLink(post.ID))
+	c.JSON(http.StatusOK, post)
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+
+	"hxzd-server/models"
+	"hxzd-server/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 关注目标类型
+const (
+	subscriptionTargetPost     = "post"
+	subscriptionTargetCategory = "category"
+)
+
+// setWatching 设置关注状态；auto 为 true 时只在没有记录时创建，不覆盖用户主动取消的关注
+func setWatching(db *gorm.DB, userID uint, targetType string, targetID uint, watching, auto bool) {
+	var sub models.Subscription
+	err := db.Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).First(&sub).Error
+	if err != nil {
+		sub = models.Subscription{UserID: userID, TargetType: targetType, TargetID: targetID}
+		if db.Create(&sub).Error != nil {
+			return
+		}
+	} else if auto {
+		return
+	}
+	if sub.Watching != watching {
+		db.Model(&sub).Update("watching", watching)
+	}
+}
+
+// isWatching 用户是否关注了目标
+func isWatching(db *gorm.DB, userID uint, targetType string, targetID uint) bool {
+	if userID == 0 {
+		return false
+	}
+	var count int64
+	db.Model(&models.Subscription{}).
+		Where("user_id = ? AND target_type = ? AND target_id = ? AND watching = ?", userID, targetType, targetID, true).
+		Count(&count)
+	return count > 0
+}
+
+// deleteSubscriptions 删除目标的全部关注记录
+func deleteSubscriptions(db *gorm.DB, targetType string, ids ...uint) {
+	if len(ids) == 0 {
+		return
+	}
+	db.Where("target_type = ? AND target_id IN ?", targetType, ids).Delete(&models.Subscription{})
+}
+
+// notifyWatchers 通知关注目标的用户；notified 中的用户已收到其他通知，看不到该分类的用户不通知
+func (h *ForumHandler) notifyWatchers(msg models.Notification, targetType string, targetID uint, cat *models.ForumCategory, notified map[uint]bool) {
+	var users []models.User
+	h.DB.Model(&models.User{}).Select("users.id", "users.role").
+		Joins("JOIN subscriptions ON subscriptions.user_id = users.id").
+		Where("subscriptions.target_type = ? AND subscriptions.target_id = ? AND subscriptions.watching = ?", targetType, targetID, true).
+		Find(&users)
+	for _, u := range users {
+		if notified[u.ID] || !canReadCategory(h.Perms, u.Role, cat) {
+			continue
+		}
+		m := msg
+		m.UserID = u.ID
+		h.Notifier.Send(m)
+	}
+}
+
+// notifyPostWatchers 新评论通知关注该帖子的用户，在后台逐个发送
+func (h *ForumHandler) notifyPostWatchers(c *gin.Context, post *models.ForumPost, comment *models.ForumComment, notified map[uint]bool) {
+	var cat models.ForumCategory
+	h.DB.Where("slug = ?", post.Category).First(&cat)
+
+	msg := actorNotification(c, notify.TypeSubscription)
+	msg.Title = c.GetString("username") + " 回复了你关注的帖子《" + post.Title + "》"
+	msg.Body = comment.Content
+	msg.Link = commentLink(post.ID, comment.ID)
+	msg.TargetType = "comment"
+	msg.TargetID = comment.ID
+	go h.notifyWatchers(msg, subscriptionTargetPost, post.ID, &cat, notified)
+}
+
+// notifyCategoryWatchers 新帖子通知关注该分类的用户，在后台逐个发送
+func (h *ForumHandler) notifyCategoryWatchers(c *gin.Context, cat *models.ForumCategory, post *models.ForumPost) {
+	msg := actorNotification(c, notify.TypeSubscription)
+	msg.Title = c.GetString("username") + " 在你关注的分类「" + cat.Name + "」发表了《" + post.Title + "》"
+	msg.Body = post.Content
+	msg.Link = postLink(post.ID)
+	msg.TargetType = "post"
+	msg.TargetID = post.ID
+	category := *cat
+	go h.notifyWatchers(msg, subscriptionTargetCategory, cat.ID, &category, map[uint]bool{post.AuthorID: true})
+}
+
+// WatchPost 关注帖子，之后的新回复会收到通知
+func (h *ForumHandler) WatchPost(c *gin.Context) {
+	h.setPostWatching(c, true)
+}
+
+// UnwatchPost 取消关注帖子，之后评论也不再自动关注
+func (h *ForumHandler) UnwatchPost(c *gin.Context) {
+	h.setPostWatching(c, false)
+}
+
+func (h *ForumHandler) setPostWatching(c *gin.Context, watching bool) {
+	var post models.ForumPost
+	if err := h.DB.First(&post, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在"})
+		return
+	}
+	if !h.viewablePost(c, &post) {
+		return
+	}
+	setWatching(h.DB, c.GetUint("user_id"), subscriptionTargetPost, post.ID, watching, false)
+	c.JSON(http.StatusOK, gin.H{"watching": watching})
+}
+
+// WatchCategory 关注分类，分类下的新帖子会收到通知
+func (h *ForumHandler) WatchCategory(c *gin.Context) {
+	h.setCategoryWatching(c, true)
+}
+
+// UnwatchCategory 取消关注分类
+func (h *ForumHandler) UnwatchCategory(c *gin.Context) {
+	h.setCategoryWatching(c, false)
+}
+
+func (h *ForumHandler) setCategoryWatching(c *gin.Context, watching bool) {
+	cat, ok := h.findCategory(c, c.Param("slug"))
+	if !ok || !h.readableCategory(c, cat.Slug) {
+		return
+	}
+	setWatching(h.DB, c.GetUint("user_id"), subscriptionTargetCategory, cat.ID, watching, false)
+	c.JSON(http.StatusOK, gin.H{"watching": watching})
+}
+
+// ListSubscriptions 当前用户关注的帖子与分类
+func (h *ForumHandler) ListSubscriptions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	var subs []models.Subscription
+	h.DB.Where("user_id = ? AND watching = ?", userID, true).Order("id DESC").Find(&subs)
+
+	var postIDs, categoryIDs []uint
+	for _, s := range subs {
+		if s.TargetType == subscriptionTargetPost {
+			postIDs = append(postIDs, s.TargetID)
+		} else {
+			categoryIDs = append(categoryIDs, s.TargetID)
+		}
+	}
+
+	role := currentRole(c)
+	categories := []models.ForumCategory{}
+	var allCategories []models.ForumCategory
+	h.DB.Order("sort_order ASC, id ASC").Find(&allCategories)
+	readable := map[string]bool{}
+	watched := map[uint]bool{}
+	for _, id := range categoryIDs {
+		watched[id] = true
+	}
+	for _, cat := range allCategories {
+		if !canReadCategory(h.Perms, role, &cat) {
+			continue
+		}
+		readable[cat.Slug] = true
+		if watched[cat.ID] {
+			cat.Watching = true
+			categories = append(categories, cat)
+		}
+	}
+
+	posts := []models.ForumPost{}
+	if len(postIDs) > 0 {
+		var found []models.ForumPost
+		h.DB.Select("id", "title", "category", "author_id", "comment_count", "is_hidden", "last_activity_at", "created_at").
+			Preload("Author").Where("id IN ?", postIDs).Order("last_activity_at DESC").Find(&found)
+		for _, p := range found {
+			if !readable[p.Category] || (p.IsHidden && !h.canSeeHidden(c, p.AuthorID)) {
+				continue
+			}
+			p.Watching = true
+			posts = append(posts, p)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"posts": posts, "categories": categories})
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const tagNameMaxLen = 32
+
+// likeEscaper 转义 LIKE 中的通配符，标签名里可能出现下划线
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+type ForumTagHandler struct {
+	DB *gorm.DB
+}
+
+func NewForumTagHandler(db *gorm.DB) *ForumTagHandler {
+	return &ForumTagHandler{DB: db}
+}
+
+// normalizeTagName 去掉首尾空白与开头的 #，转为小写；只允许文字、数字、空格和 . _ - +
+func normalizeTagName(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "#")))
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || utf8.RuneCountInString(name) > tagNameMaxLen {
+		return "", false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" ._-+", r) {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// parseTagQuery 解析逗号分隔的标签筛选参数，忽略格式不正确的标签
+func parseTagQuery(s string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, part := range strings.Split(s, ",") {
+		if name, ok := normalizeTagName(part); ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resolveTags 校验提交的标签名，返回去重后的规范名称；不创建标签，新标签在帖子写入时由 ensureTags 创建。
+// 关闭自由标签后普通用户只能使用已有标签
+func (h *ForumHandler) resolveTags(c *gin.Context, names []string) ([]string, bool) {
+	seen := map[string]bool{}
+	cleaned := []string{}
+	for _, n := range names {
+		name, ok := normalizeTagName(n)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "标签「" + n + "」格式不正确"})
+			return nil, false
+		}
+		if !seen[name] {
+			seen[name] = true
+			cleaned = append(cleaned, name)
+		}
+	}
+	max, err := strconv.Atoi(utils.GetSetting(h.DB, "forum_tag_max_per_post", "5"))
+	if err != nil || max < 0 {
+		max = 5
+	}
+	if max > 0 && len(cleaned) > max {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("每个帖子最多 %d 个标签", max)})
+		return nil, false
+	}
+	if len(cleaned) == 0 || utils.GetSetting(h.DB, "forum_tag_free_form", "true") == "true" || h.isModerator(c) {
+		return cleaned, true
+	}
+
+	var existing []string
+	h.DB.Model(&models.ForumTag{}).Where("name IN ?", cleaned).Pluck("name", &existing)
+	found := map[string]bool{}
+	for _, name := range existing {
+		found[name] = true
+	}
+	for _, name := range cleaned {
+		if !found[name] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "标签「" + name + "」不存在，请从已有标签中选择"})
+			return nil, false
+		}
+	}
+	return cleaned, true
+}
+
+// ensureTags 在帖子写入的事务中取得标签，不存在的自动创建
+func ensureTags(tx *gorm.DB, names []string) ([]models.ForumTag, error) {
+	tags := []models.ForumTag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+	if err := tx.Where("name IN ?", names).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	found := map[string]bool{}
+	for _, t := range tags {
+		found[t.Name] = true
+	}
+	for _, name := range names {
+		if found[name] {
+			continue
+		}
+		// 并发创建同名标签时以先写入的为准
+		tag := models.ForumTag{Name: name}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag).Error; err != nil {
+			return nil, err
+		}
+		if tag.ID == 0 {
+			if err := tx.Where("name = ?", name).First(&tag).Error; err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// setPostTags 替换帖子的标签并更新相关标签的帖子数
+func setPostTags(db *gorm.DB, postID uint, tags []models.ForumTag) {
+	var ids []uint
+	db.Model(&models.ForumPostTag{}).Where("post_id = ?", postID).Pluck("tag_id", &ids)
+	db.Where("post_id = ?", postID).Delete(&models.ForumPostTag{})
+	rows := make([]models.ForumPostTag, 0, len(tags))
+	for _, t := range tags {
+		rows = append(rows, models.ForumPostTag{PostID: postID, TagID: t.ID})
+		ids = append(ids, t.ID)
+	}
+	if len(rows) > 0 {
+		db.Create(&rows)
+	}
+	refreshTagCounts(db, ids...)
+}
+
+// refreshTagCounts 重新统计标签下公开可见的帖子数：未删除、未隐藏且所在分类没有阅读权限限制。
+// 公开的标签列表与自动补全会展示该数字，不能泄露受限分类或隐藏帖子的存在
+func refreshTagCounts(db *gorm.DB, tagIDs ...uint) {
+	if len(tagIDs) == 0 {
+		return
+	}
+	db.Model(&models.ForumTag{}).Where("id IN ?", tagIDs).UpdateColumn("post_count", gorm.Expr(
+		"(SELECT COUNT(*) FROM forum_post_tags JOIN forum_posts ON forum_posts.id = forum_post_tags.post_id "+
+			"WHERE forum_post_tags.tag_id = forum_tags.id AND forum_posts.deleted_at IS NULL AND forum_posts.is_hidden = ? "+
+			"AND forum_posts.category NOT IN (SELECT slug FROM forum_categories WHERE read_permission <> ''))", false))
+	pruneTags(db, tagIDs...)
+}
+
+// refreshCategoryTagCounts 分类的阅读权限变化或帖子迁入后，更新该分类下帖子用到的标签
+func refreshCategoryTagCounts(db *gorm.DB, slug string) {
+	var ids []uint
+	db.Model(&models.ForumPostTag{}).Distinct().
+		Where("post_id IN (?)", db.Unscoped().Model(&models.ForumPost{}).Select("id").Where("category = ?", slug)).
+		Pluck("tag_id", &ids)
+	refreshTagCounts(db, ids...)
+}
+
+// pruneTags 删除已不再被任何帖子（含回收站中的帖子）使用的非推荐标签
+func pruneTags(db *gorm.DB, tagIDs ...uint) {
+	db.Where("id IN ? AND curated = ?", tagIDs, false).
+		Where("NOT EXISTS (SELECT 1 FROM forum_post_tags WHERE forum_post_tags.tag_id = forum_tags.id)").
+		Delete(&models.ForumTag{})
+}
+
+// refreshPostTagCounts 帖子删除、恢复、隐藏或移动分类后更新其标签的帖子数
+func refreshPostTagCounts(db *gorm.DB, postID uint) {
+	var ids []uint
+	db.Model(&models.ForumPostTag{}).Where("post_id = ?", postID).Pluck("tag_id", &ids)
+	refreshTagCounts(db, ids...)
+}
+
+// publicTags 公开可见的标签：推荐标签，或至少被一篇公开帖子使用
+func publicTags(db *gorm.DB) *gorm.DB {
+	return db.Where("(curated = ? OR post_count > 0)", true)
+}
+
+// ListTags 公开接口 — 标签列表与自动补全，q 按前缀匹配，curated=true 只返回推荐标签
+func (h *ForumTagHandler) ListTags(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	query := publicTags(h.DB.Model(&models.ForumTag{}))
+	if q := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.Query("q")), "#")); q != "" {
+		query = query.Where("name LIKE ?", likeEscaper.Replace(q)+"%")
+	}
+	if c.Query("curated") == "true" {
+		query = query.Where("curated = ?", true)
+	}
+	tags := []models.ForumTag{}
+	query.Order("curated DESC, post_count DESC, name ASC").Limit(limit).Find(&tags)
+	c.JSON(http.StatusOK, tags)
+}
+
+// GetTag 公开接口 — 标签页信息，帖子列表使用 /forum/posts?tags=
+func (h *ForumTagHandler) GetTag(c *gin.Context) {
+	name, _ := normalizeTagName(c.Param("name"))
+	var tag models.ForumTag
+	if name == "" || publicTags(h.DB).Where("name = ?", name).First(&tag).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+// AdminListTags 版主 — 全部标签
+func (h *ForumTagHandler) AdminListTags(c *gin.Context) {
+	var tags []models.ForumTag
+	h.DB.Order("curated DESC, post_count DESC, name ASC").Find(&tags)
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag 版主 — 新建标签，默认作为推荐标签
+func (h *ForumTagHandler) CreateTag(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description" binding:"max=255"`
+		Curated     *bool  `json:"curated"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	name, ok := normalizeTagName(req.Name)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标签名不超过 32 个字，只能包含文字、数字、空格和 . _ - +"})
+		return
+	}
+	var existing models.ForumTag
+	if h.DB.Where("name = ?", name).First(&existing).Error == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "标签已存在"})
+		return
+	}
+
+	tag := models.ForumTag{Name: name, Description: req.Description, Curated: req.Curated == nil || *req.Curated}
+	h.DB.Create(&tag)
+	utils.RecordAudit(h.DB, c, "forum_tag.create", "forum_tag", tag.ID, nil, tag)
+	c.JSON(http.StatusOK, tag)
+}
+
+// UpdateTag 版主 — 重命名标签或修改说明、推荐状态；改成已有的名字时应使用合并
+func (h *ForumTagHandler) UpdateTag(c *gin.Context) {
+	var tag models.ForumTag
+	if err := h.DB.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description" binding:"omitempty,max=255"`
+		Curated     *bool   `json:"curated"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name, ok := normalizeTagName(*req.Name)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "标签名不超过 32 个字，只能包含文字、数字、空格和 . _ - +"})
+			return
+		}
+		if name != tag.Name {
+			var existing models.ForumTag
+			if h.DB.Where("name = ? AND id <> ?", name, tag.ID).First(&existing).Error == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "标签「" + name + "」已存在，请使用合并"})
+				return
+			}
+			updates["name"] = name
+		}
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Curated != nil {
+		updates["curated"] = *req.Curated
+	}
+
+	before := tag
+	h.DB.Model(&tag).Updates(updates)
+	h.DB.First(&tag, tag.ID)
+	utils.RecordAudit(h.DB, c, "forum_tag.update", "forum_tag", tag.ID, before, tag)
+	c.JSON(http.StatusOK, tag)
+}
+
+// MergeTag 版主 — 把标签合并到 {"into_id": 目标标签}，原标签的帖子改用目标标签，原标签删除
+func (h *ForumTagHandler) MergeTag(c *gin.Context) {
+	var source models.ForumTag
+	if err := h.DB.First(&source, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+	var req struct {
+		IntoID uint `json:"into_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	var target models.ForumTag
+	if req.IntoID == source.ID || h.DB.First(&target, req.IntoID).Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "合并目标标签无效"})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// 同时带有两个标签的帖子只保留目标标签
+		var both []uint
+		tx.Model(&models.ForumPostTag{}).Where("tag_id = ?", target.ID).Pluck("post_id", &both)
+		if len(both) > 0 {
+			if err := tx.Where("tag_id = ? AND post_id IN ?", source.ID, both).Delete(&models.ForumPostTag{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.ForumPostTag{}).Where("tag_id = ?", source.ID).Update("tag_id", target.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&source).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "合并失败"})
+		return
+	}
+	refreshTagCounts(h.DB, target.ID)
+	h.DB.First(&target, target.ID)
+	utils.RecordAudit(h.DB, c, "forum_tag.merge", "forum_tag", source.ID, source, target)
+	c.JSON(http.StatusOK, target)
+}
+
+// DeleteTag 版主 — 删除标签，并从所有帖子上移除
+func (h *ForumTagHandler) DeleteTag(c *gin.Context) {
+	var tag models.ForumTag
+	if err := h.DB.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+	h.DB.Where("tag_id = ?", tag.ID).Delete(&models.ForumPostTag{})
+	h.DB.Delete(&tag)
+	utils.RecordAudit(h.DB, c, "forum_tag.delete", "forum_tag", tag.ID, tag, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
+}
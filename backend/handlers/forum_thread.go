@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"hxzd-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxCommentDepth 评论最大嵌套层级（顶层为 0）
+const maxCommentDepth = 4
+
+const (
+	deletedCommentText = "[deleted]"
+	hiddenCommentText  = "[hidden]"
+)
+
+// findPostComment 查找同一帖子下未删除的评论，失败时写入 400 响应
+func (h *ForumHandler) findPostComment(c *gin.Context, postID, commentID uint, notFound string) (*models.ForumComment, bool) {
+	var comment models.ForumComment
+	if h.DB.Where("id = ? AND post_id = ? AND is_deleted = ?", commentID, postID, false).First(&comment).Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": notFound})
+		return nil, false
+	}
+	return &comment, true
+}
+
+// maskDeletedComment 已删除的占位评论不再暴露原作者与内容
+func maskDeletedComment(comment *models.ForumComment) {
+	if !comment.IsDeleted {
+		return
+	}
+	comment.Content = deletedCommentText
+	comment.ContentHTML = ""
+	comment.AuthorID = 0
+	comment.Author = models.User{}
+	comment.DeletedByID = nil
+	comment.DeleteReason = ""
+}
+
+// maskHiddenComment 被隐藏的评论对普通用户只保留占位
+func maskHiddenComment(comment *models.ForumComment) {
+	comment.Content = hiddenCommentText
+	comment.ContentHTML = ""
+}
+
+// buildCommentTree 把按时间排序的评论组装成嵌套结构，父评论缺失的回复提升为顶层
+func buildCommentTree(comments []models.ForumComment) []models.ForumComment {
+	ids := make(map[uint]bool, len(comments))
+	for _, cm := range comments {
+		ids[cm.ID] = true
+	}
+	children := map[uint][]models.ForumComment{}
+	var roots []models.ForumComment
+	for _, cm := range comments {
+		if cm.ParentID != nil && ids[*cm.ParentID] {
+			children[*cm.ParentID] = append(children[*cm.ParentID], cm)
+		} else {
+			roots = append(roots, cm)
+		}
+	}
+
+	var attach func(list []models.ForumComment) []models.ForumComment
+	attach = func(list []models.ForumComment) []models.ForumComment {
+		for i := range list {
+			list[i].Replies = attach(children[list[i].ID])
+		}
+		return list
+	}
+	return attach(roots)
+}
+
+// pruneDeletedComments 去掉没有未删除后代的已删除评论，其余已删除评论作为占位保留；
+// comments 需按创建时间升序排列
+func pruneDeletedComments(comments []models.ForumComment) []models.ForumComment {
+	keep := make(map[uint]bool, len(comments))
+	for i := len(comments) - 1; i >= 0; i-- {
+		cm := comments[i]
+		if !cm.IsDeleted || keep[cm.ID] {
+			keep[cm.ID] = true
+			if cm.ParentID != nil {
+				keep[*cm.ParentID] = true
+			}
+		}
+	}
+	result := comments[:0]
+	for _, cm := range comments {
+		if keep[cm.ID] {
+			result = append(result, cm)
+		}
+	}
+	return result
+}
+
+// removePost 把帖子移入回收站，评论、回应与历史版本保留到彻底删除时再清理
+func removePost(db *gorm.DB, post *models.ForumPost, deletedBy uint, reason string) {
+	db.Model(post).UpdateColumns(map[string]interface{}{
+		"deleted_by_id": deletedBy,
+		"delete_reason": reason,
+	})
+	db.Delete(post)
+	touchCategory(db, post.Category, -1)
+	refreshPostTagCounts(db, post.ID)
+}
+
+// removeComment 把评论移入回收站，内容保留以便恢复
+func removeComment(db *gorm.DB, comment *models.ForumComment, deletedBy uint, reason string) {
+	db.Model(&models.ForumPost{}).Where("id = ?", comment.PostID).
+		UpdateColumn("comment_count", gorm.Expr("comment_count - 1"))
+	db.Model(comment).UpdateColumns(map[string]interface{}{
+		"is_deleted":    true,
+		"deleted_at":    time.Now(),
+		"deleted_by_id": deletedBy,
+		"delete_reason": reason,
+	})
+}
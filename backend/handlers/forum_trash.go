@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashComment 回收站中的评论，附带所属帖子信息
+type trashComment struct {
+	models.ForumComment
+	PostTitle   string `json:"post_title"`
+	PostDeleted bool   `json:"post_deleted"`
+}
+
+type ForumTrashHandler struct {
+	DB *gorm.DB
+}
+
+func NewForumTrashHandler(db *gorm.DB) *ForumTrashHandler {
+	h := &ForumTrashHandler{DB: db}
+	go h.purgeLoop()
+	return h
+}
+
+func (h *ForumTrashHandler) purgeLoop() {
+	h.purgeExpired()
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.purgeExpired()
+	}
+}
+
+// purgeExpired 按 forum_trash_retention_days 设置彻底删除回收站中的过期内容，0 表示永久保留
+func (h *ForumTrashHandler) purgeExpired() {
+	days, err := strconv.Atoi(utils.GetSetting(h.DB, "forum_trash_retention_days", "0"))
+	if err != nil || days <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	var posts []models.ForumPost
+	h.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Find(&posts)
+	for i := range posts {
+		purgePost(h.DB, &posts[i])
+	}
+	var comments []models.ForumComment
+	h.DB.Where("is_deleted = ? AND deleted_at < ?", true, cutoff).Find(&comments)
+	for i := range comments {
+		purgeComment(h.DB, &comments[i])
+	}
+	if len(posts)+len(comments) > 0 {
+		log.Printf("[forum] purged %d posts and %d comments deleted more than %d days ago", len(posts), len(comments), days)
+	}
+}
+
+// purgePost 彻底删除帖子及其评论、回应与历史版本，附件解除关联后由回收任务清理
+func purgePost(db *gorm.DB, post *models.ForumPost) {
+	var commentIDs []uint
+	db.Model(&models.ForumComment{}).Where("post_id = ?", post.ID).Pluck("id", &commentIDs)
+	deleteReactions(db, reactionTargetComment, commentIDs...)
+	deleteReactions(db, reactionTargetPost, post.ID)
+	releaseAttachments(db, attachmentTargetComment, commentIDs...)
+	releaseAttachments(db, attachmentTargetPost, post.ID)
+	deleteSubscriptions(db, subscriptionTargetPost, post.ID)
+	deletePoll(db, post.ID)
+	var tagIDs []uint
+	db.Model(&models.ForumPostTag{}).Where("post_id = ?", post.ID).Pluck("tag_id", &tagIDs)
+	db.Where("post_id = ?", post.ID).Delete(&models.ForumPostTag{})
+	db.Where("post_id = ?", post.ID).Delete(&models.ForumComment{})
+	db.Where("post_id = ?", post.ID).Delete(&models.ForumPostRevision{})
+	db.Unscoped().Delete(post)
+	refreshTagCounts(db, tagIDs...)
+}
+
+// purgeComment 彻底删除评论；仍有回复的评论清空内容后保留为占位，并移出回收站
+func purgeComment(db *gorm.DB, comment *models.ForumComment) {
+	deleteReactions(db, reactionTargetComment, comment.ID)
+	releaseAttachments(db, attachmentTargetComment, comment.ID)
+	var replies int64
+	db.Model(&models.ForumComment{}).Where("parent_id = ?", comment.ID).Count(&replies)
+	if replies > 0 {
+		db.Model(comment).UpdateColumns(map[string]interface{}{
+			"content":       "",
+			"content_html":  "",
+			"deleted_at":    nil,
+			"delete_reason": "",
+		})
+		return
+	}
+	db.Delete(comment)
+}
+
+// ListTrash 管理员 — 回收站，type=post（默认）或 comment
+func (h *ForumTrashHandler) ListTrash(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+	var total int64
+
+	if c.DefaultQuery("type", "post") == "comment" {
+		query := h.DB.Model(&models.ForumComment{}).Where("is_deleted = ? AND deleted_at IS NOT NULL", true)
+		query.Count(&total)
+
+		var comments []models.ForumComment
+		query.Preload("Author").Preload("DeletedBy").
+			Order("deleted_at DESC").
+			Offset((page - 1) * size).
+			Limit(size).
+			Find(&comments)
+
+		postIDs := make([]uint, len(comments))
+		for i, cm := range comments {
+			postIDs[i] = cm.PostID
+		}
+		var posts []models.ForumPost
+		h.DB.Unscoped().Select("id", "title", "deleted_at").Where("id IN ?", postIDs).Find(&posts)
+		postByID := make(map[uint]models.ForumPost, len(posts))
+		for _, p := range posts {
+			postByID[p.ID] = p
+		}
+
+		items := make([]trashComment, len(comments))
+		for i, cm := range comments {
+			p := postByID[cm.PostID]
+			items[i] = trashComment{ForumComment: cm, PostTitle: p.Title, PostDeleted: p.DeletedAt.Valid}
+		}
+		c.JSON(http.StatusOK, gin.H{"comments": items, "total": total, "page": page, "size": size})
+		return
+	}
+
+	query := h.DB.Unscoped().Model(&models.ForumPost{}).Where("deleted_at IS NOT NULL")
+	query.Count(&total)
+
+	var posts []models.ForumPost
+	query.Preload("Author").Preload("DeletedBy").
+		Order("deleted_at DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&posts)
+	c.JSON(http.StatusOK, gin.H{"posts": posts, "total": total, "page": page, "size": size})
+}
+
+func (h *ForumTrashHandler) trashedPost(c *gin.Context) (*models.ForumPost, bool) {
+	var post models.ForumPost
+	if err := h.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&post, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中没有该帖子"})
+		return nil, false
+	}
+	return &post, true
+}
+
+func (h *ForumTrashHandler) trashedComment(c *gin.Context) (*models.ForumComment, bool) {
+	var comment models.ForumComment
+	if err := h.DB.Where("is_deleted = ? AND deleted_at IS NOT NULL", true).First(&comment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回收站中没有该评论"})
+		return nil, false
+	}
+	return &comment, true
+}
+
+// RestorePost 管理员 — 从回收站恢复帖子；原分类已删除时需通过 ?category= 指定新分类
+func (h *ForumTrashHandler) RestorePost(c *gin.Context) {
+	post, ok := h.trashedPost(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"deleted_at":    nil,
+		"deleted_by_id": nil,
+		"delete_reason": "",
+	}
+	category := post.Category
+	var cat models.ForumCategory
+	if h.DB.Where("slug = ?", category).First(&cat).Error != nil {
+		category = c.Query("category")
+		if category == "" || h.DB.Where("slug = ?", category).First(&cat).Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "原分类已不存在，请指定恢复到的分类"})
+			return
+		}
+		updates["category"] = category
+	}
+
+	before := *post
+	h.DB.Unscoped().Model(post).UpdateColumns(updates)
+	touchCategory(h.DB, category, 1)
+	refreshPostTagCounts(h.DB, post.ID)
+	h.DB.Preload("Author").First(post, post.ID)
+	utils.RecordAudit(h.DB, c, "forum_post.trash_restore", "forum_post", post.ID, before, post)
+	c.JSON(http.StatusOK, post)
+}
+
+// RestoreComment 管理员 — 从回收站恢复评论，所属帖子需未被删除
+func (h *ForumTrashHandler) RestoreComment(c *gin.Context) {
+	comment, ok := h.trashedComment(c)
+	if !ok {
+		return
+	}
+	var post models.ForumPost
+	if h.DB.First(&post, comment.PostID).Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "所属帖子已在回收站，请先恢复帖子"})
+		return
+	}
+
+	before := *comment
+	h.DB.Model(comment).UpdateColumns(map[string]interface{}{
+		"is_deleted":    false,
+		"deleted_at":    nil,
+		"deleted_by_id": nil,
+		"delete_reason": "",
+	})
+	h.DB.Model(&post).UpdateColumn("comment_count", gorm.Expr("comment_count + 1"))
+	h.DB.Preload("Author").First(comment, comment.ID)
+	utils.RecordAudit(h.DB, c, "forum_comment.trash_restore", "forum_comment", comment.ID, before, comment)
+	c.JSON(http.StatusOK, comment)
+}
+
+// PurgePost 管理员 — 立即彻底删除回收站中的帖子
+func (h *ForumTrashHandler) PurgePost(c *gin.Context) {
+	post, ok := h.trashedPost(c)
+	if !ok {
+		return
+	}
+	purgePost(h.DB, post)
+	utils.RecordAudit(h.DB, c, "forum_post.purge", "forum_post", post.ID, post, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "已彻底删除"})
+}
+
+// PurgeComment 管理员 — 立即彻底删除回收站中的评论
+func (h *ForumTrashHandler) PurgeComment(c *gin.Context) {
+	comment, ok := h.trashedComment(c)
+	if !ok {
+		return
+	}
+	purgeComment(h.DB, comment)
+	utils.RecordAudit(h.DB, c, "forum_comment.purge", "forum_comment", comment.ID, comment, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "已彻底删除"})
+}
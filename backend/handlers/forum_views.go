@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// viewFlushInterval 浏览量写回数据库的间隔
+	viewFlushInterval = 10 * time.Second
+	// maxTrackedViews 去重表的上限，超过后新访客仍计数但不再去重，避免内存无限增长
+	maxTrackedViews = 200_000
+)
+
+// crawlerPattern 常见爬虫、预览抓取与命令行工具的 User-Agent
+var crawlerPattern = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|archiver|facebookexternalhit|embedly|preview|curl|wget|python-|go-http-client|java/|okhttp|headless|phantomjs|lighthouse`)
+
+func isCrawler(userAgent string) bool {
+	return userAgent == "" || crawlerPattern.MatchString(userAgent)
+}
+
+// viewCounter 帖子浏览量计数：同一用户（访客按 IP 摘要）在时间窗口内重复浏览只计一次，
+// 增量先累积在内存中，定期按增量分组批量写回，避免热门帖子的行锁争用
+type viewCounter struct {
+	db     *gorm.DB
+	salt   []byte
+	window atomic.Int64
+
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	pending map[uint]int
+}
+
+func newViewCounter(db *gorm.DB) *viewCounter {
+	vc := &viewCounter{
+		db:      db,
+		salt:    make([]byte, 16),
+		seen:    map[string]time.Time{},
+		pending: map[uint]int{},
+	}
+	// 访客 IP 只以加盐摘要形式保存在内存中
+	rand.Read(vc.salt)
+	vc.loadWindow()
+	go vc.flushLoop()
+	return vc
+}
+
+// loadWindow 读取 forum_view_window_minutes 设置
+func (vc *viewCounter) loadWindow() {
+	minutes, err := strconv.Atoi(utils.GetSetting(vc.db, "forum_view_window_minutes", "30"))
+	if err != nil || minutes < 0 {
+		minutes = 30
+	}
+	vc.window.Store(int64(time.Duration(minutes) * time.Minute))
+}
+
+func (vc *viewCounter) viewerKey(c *gin.Context) string {
+	if userID := c.GetUint("user_id"); userID != 0 {
+		return "u" + strconv.FormatUint(uint64(userID), 10)
+	}
+	sum := sha256.Sum256(append(append([]byte{}, vc.salt...), c.ClientIP()...))
+	return "g" + hex.EncodeToString(sum[:12])
+}
+
+// Record 记录一次浏览，爬虫、作者本人以及窗口内的重复浏览不计数
+func (vc *viewCounter) Record(c *gin.Context, post *models.ForumPost) {
+	if isCrawler(c.Request.UserAgent()) {
+		return
+	}
+	if userID := c.GetUint("user_id"); userID != 0 && userID == post.AuthorID {
+		return
+	}
+	key := strconv.FormatUint(uint64(post.ID), 10) + "|" + vc.viewerKey(c)
+	now := time.Now()
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if seenAt, ok := vc.seen[key]; ok && now.Sub(seenAt) < time.Duration(vc.window.Load()) {
+		return
+	}
+	if len(vc.seen) < maxTrackedViews {
+		vc.seen[key] = now
+	}
+	vc.pending[post.ID]++
+}
+
+// Pending 尚未写回数据库的浏览量
+func (vc *viewCounter) Pending(postID uint) int {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	return vc.pending[postID]
+}
+
+func (vc *viewCounter) flushLoop() {
+	ticker := time.NewTicker(viewFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		vc.flush()
+	}
+}
+
+// flush 写回累积的浏览量，增量相同的帖子合并为一条 UPDATE，并清理过期的去重记录
+func (vc *viewCounter) flush() {
+	vc.loadWindow()
+	window := time.Duration(vc.window.Load())
+	now := time.Now()
+
+	vc.mu.Lock()
+	pending := vc.pending
+	vc.pending = map[uint]int{}
+	for key, seenAt := range vc.seen {
+		if now.Sub(seenAt) >= window {
+			delete(vc.seen, key)
+		}
+	}
+	vc.mu.Unlock()
+
+	groups := map[int][]uint{}
+	for postID, n := range pending {
+		groups[n] = append(groups[n], postID)
+	}
+	for n, ids := range groups {
+		if err := vc.db.Model(&models.ForumPost{}).Where("id IN ?", ids).
+			UpdateColumn("view_count", gorm.Expr("view_count + ?", n)).Error; err != nil {
+			log.Printf("[forum] flush %d view counts failed: %v", len(ids), err)
+		}
+	}
+}
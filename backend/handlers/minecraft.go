@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"hxzd-server/config"
+	"hxzd-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 去掉易混淆字符，方便玩家在游戏内手动输入
+const linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var (
+	mcUUIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{12}$`)
+	mcNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,16}$`)
+)
+
+type MinecraftHandler struct {
+	DB  *gorm.DB
+	Cfg *config.Config
+}
+
+func NewMinecraftHandler(db *gorm.DB, cfg *config.Config) *MinecraftHandler {
+	return &MinecraftHandler{DB: db, Cfg: cfg}
+}
+
+func generateLinkCode(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = linkCodeAlphabet[int(b)%len(linkCodeAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// normalizeUUID 统一为带连字符的小写格式
+func normalizeUUID(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", "")
+	if len(s) != 32 {
+		return s
+	}
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// minecraftNameTaken 玩家名已被其他用户通过游戏内验证绑定时写入 409 响应并返回 true；
+// userID 为当前用户，注册时传 0
+func minecraftNameTaken(c *gin.Context, db *gorm.DB, name string, userID uint) bool {
+	if name == "" {
+		return false
+	}
+	var owner models.User
+	if db.Where("minecraft_id = ? AND minecraft_uuid IS NOT NULL AND id <> ?", name, userID).First(&owner).Error != nil {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "该玩家名已被其他用户验证绑定"})
+	return true
+}
+
+// IssueCode 登录用户 — 生成游戏内绑定验证码
+func (h *MinecraftHandler) IssueCode(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	code, err := generateLinkCode(8)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证码生成失败"})
+		return
+	}
+
+	// 每个用户只保留最新的一个验证码
+	h.DB.Where("user_id = ?", userID).Delete(&models.MinecraftLinkCode{})
+
+	item := models.MinecraftLinkCode{
+		UserID:    userID.(uint),
+		Code:      code,
+		ExpiresAt: time.Now().Add(h.Cfg.LinkCodeTTL),
+	}
+	if err := h.DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证码生成失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":       item.Code,
+		"command":    "/link " + item.Code,
+		"expires_at": item.ExpiresAt,
+	})
+}
+
+// Unlink 登录用户 — 解除 Minecraft 账号绑定
+func (h *MinecraftHandler) Unlink(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	h.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"minecraft_uuid":      nil,
+		"minecraft_linked_at": nil,
+	})
+	h.DB.Where("user_id = ?", userID).Delete(&models.MinecraftLinkCode{})
+	c.JSON(http.StatusOK, gin.H{"message": "已解除绑定"})
+}
+
+// ConfirmLink 插件回调 — 玩家在游戏内输入验证码后由插件提交 UUID 与名称
+func (h *MinecraftHandler) ConfirmLink(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+		UUID string `json:"uuid" binding:"required"`
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	uuid := normalizeUUID(req.UUID)
+	if !mcUUIDPattern.MatchString(uuid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 UUID"})
+		return
+	}
+	if !mcNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的玩家名"})
+		return
+	}
+
+	var linkCode models.MinecraftLinkCode
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if err := h.DB.Where("code = ?", code).First(&linkCode).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "验证码不存在"})
+		return
+	}
+	if time.Now().After(linkCode.ExpiresAt) {
+		h.DB.Delete(&linkCode)
+		c.JSON(http.StatusGone, gin.H{"error": "验证码已过期"})
+		return
+	}
+
+	var existing models.User
+	if h.DB.Where("minecraft_uuid = ? AND id != ?", uuid, linkCode.UserID).First(&existing).Error == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "该 Minecraft 账号已绑定其他用户"})
+		return
+	}
+
+	var user models.User
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.User{}).Where("id = ?", linkCode.UserID).Updates(map[string]interface{}{
+			"minecraft_uuid":      uuid,
+			"minecraft_id":        req.Name,
+			"minecraft_linked_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		// 其他未验证用户填写的同名玩家名一并清除，避免冒用
+		if err := tx.Model(&models.User{}).
+			Where("minecraft_id = ? AND minecraft_uuid IS NULL AND id <> ?", req.Name, linkCode.UserID).
+			Update("minecraft_id", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&linkCode).Error; err != nil {
+			return err
+		}
+		return tx.First(&user, linkCode.UserID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "绑定失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "绑定成功",
+		"username": user.Username,
+	})
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/notify"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 实时通道的心跳间隔，避免代理因空闲断开连接
+const notificationPingInterval = 25 * time.Second
+
+type NotificationHandler struct {
+	DB       *gorm.DB
+	Notifier *notify.Notifier
+	Digester *notify.Digester
+}
+
+func NewNotificationHandler(db *gorm.DB, notifier *notify.Notifier, digester *notify.Digester) *NotificationHandler {
+	return &NotificationHandler{DB: db, Notifier: notifier, Digester: digester}
+}
+
+// unreadCount 用户未读通知数
+func unreadCount(db *gorm.DB, userID uint) int64 {
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// List 当前用户的通知，unread=1 时只返回未读
+func (h *NotificationHandler) List(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 50 {
+		size = 20
+	}
+
+	query := h.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "1" {
+		query = query.Where("read_at IS NULL")
+	}
+	if t := c.Query("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.Notification
+	query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&items)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": items,
+		"total":         total,
+		"unread":        unreadCount(h.DB, userID),
+		"page":          page,
+		"size":          size,
+	})
+}
+
+// MarkRead 标记单条通知已读
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	result := h.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", c.Param("id"), userID).
+		UpdateColumn("read_at", time.Now())
+	if result.RowsAffected == 0 {
+		var count int64
+		h.DB.Model(&models.Notification{}).Where("id = ? AND user_id = ?", c.Param("id"), userID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "通知不存在"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"unread": unreadCount(h.DB, userID)})
+}
+
+// MarkAllRead 全部标记已读
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	h.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		UpdateColumn("read_at", time.Now())
+	c.JSON(http.StatusOK, gin.H{"unread": 0})
+}
+
+// GetPreferences 各通知类型的开关
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	result := make([]gin.H, 0, len(notify.Types))
+	for _, t := range notify.Types {
+		result = append(result, gin.H{
+			"key":     t.Key,
+			"label":   t.Label,
+			"enabled": h.Notifier.Enabled(userID, t.Key),
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdatePreferences 提交 {"类型": true/false}
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	var req map[string]bool
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	for t := range req {
+		if !notify.IsValidType(t) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "未知的通知类型: " + t})
+			return
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	for t, enabled := range req {
+		var pref models.NotificationPreference
+		if h.DB.Where("user_id = ? AND type = ?", userID, t).First(&pref).Error != nil {
+			h.DB.Create(&models.NotificationPreference{UserID: userID, Type: t, Enabled: enabled})
+		} else {
+			h.DB.Model(&pref).Update("enabled", enabled)
+		}
+	}
+	h.GetPreferences(c)
+}
+
+// GetEmailDigest 通知邮件的发送频率
+func (h *NotificationHandler) GetEmailDigest(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	frequency := notify.DigestOff
+	var digest models.NotificationDigest
+	if h.DB.Where("user_id = ?", userID).First(&digest).Error == nil {
+		frequency = digest.Frequency
+	}
+	var user models.User
+	h.DB.Select("id", "email").First(&user, userID)
+	c.JSON(http.StatusOK, gin.H{
+		"frequency":   frequency,
+		"frequencies": notify.DigestFrequencies,
+		"email":       user.Email,
+		"enabled":     h.Digester.Enabled(),
+	})
+}
+
+// UpdateEmailDigest 提交 {"frequency": "off|instant|daily|weekly"}；只发送设置之后产生的通知
+func (h *NotificationHandler) UpdateEmailDigest(c *gin.Context) {
+	var req struct {
+		Frequency string `json:"frequency" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !notify.IsValidDigest(req.Frequency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if req.Frequency == notify.DigestOff {
+		h.DB.Where("user_id = ?", userID).Delete(&models.NotificationDigest{})
+		h.GetEmailDigest(c)
+		return
+	}
+	var user models.User
+	h.DB.Select("id", "email").First(&user, userID)
+	if user.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先在个人资料中填写邮箱"})
+		return
+	}
+
+	var digest models.NotificationDigest
+	if h.DB.Where("user_id = ?", userID).First(&digest).Error != nil {
+		var latestID uint
+		h.DB.Model(&models.Notification{}).Where("user_id = ?", userID).
+			Select("COALESCE(MAX(id), 0)").Scan(&latestID)
+		now := time.Now()
+		h.DB.Create(&models.NotificationDigest{
+			UserID:             userID,
+			Frequency:          req.Frequency,
+			LastNotificationID: latestID,
+			LastSentAt:         &now,
+		})
+	} else if digest.Frequency != req.Frequency {
+		h.DB.Model(&digest).Update("frequency", req.Frequency)
+	}
+	h.GetEmailDigest(c)
+}
+
+// Stream 以 Server-Sent Events 实时推送新通知
+func (h *NotificationHandler) Stream(c *gin.Context) {
+	ch, cancel := h.Notifier.Hub.Subscribe(c.GetUint("user_id"))
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(notificationPingInterval)
+	defer ticker.Stop()
+
+	c.SSEvent("ready", gin.H{"unread": unreadCount(h.DB, c.GetUint("user_id"))})
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case n := <-ch:
+			c.SSEvent("notification", n)
+			return true
+		case <-ticker.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
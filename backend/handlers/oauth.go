@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"hxzd-server/config"
+	"hxzd-server/models"
+	"hxzd-server/oauth"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OAuthHandler struct {
+	DB    *gorm.DB
+	Cfg   *config.Config
+	OAuth *oauth.Manager
+}
+
+func NewOAuthHandler(db *gorm.DB, cfg *config.Config, manager *oauth.Manager) *OAuthHandler {
+	return &OAuthHandler{DB: db, Cfg: cfg, OAuth: manager}
+}
+
+// Providers 公开接口 — 已配置的第三方登录方式
+func (h *OAuthHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, h.OAuth.Providers())
+}
+
+// Start 公开接口 — 跳转到第三方授权页
+func (h *OAuthHandler) Start(c *gin.Context) {
+	authURL, state, err := h.OAuth.Begin(c.Request.Context(), c.Param("provider"), 0)
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+	utils.SetOAuthStateCookie(c, h.Cfg, state, int(oauth.StateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Link 登录用户 — 返回绑定第三方账号的授权地址
+func (h *OAuthHandler) Link(c *gin.Context) {
+	authURL, state, err := h.OAuth.Begin(c.Request.Context(), c.Param("provider"), c.GetUint("user_id"))
+	if err != nil {
+		if errors.Is(err, oauth.ErrUnknownProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, oauth.ErrBusy) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[oauth] begin %s: %v", c.Param("provider"), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "第三方登录服务不可用"})
+		return
+	}
+	utils.SetOAuthStateCookie(c, h.Cfg, state, int(oauth.StateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// redirectError 回到登录页并在 fragment 中带上错误信息
+func (h *OAuthHandler) redirectError(c *gin.Context, err error) {
+	msg := err.Error()
+	if !errors.Is(err, oauth.ErrUnknownProvider) && !errors.Is(err, oauth.ErrInvalidState) && !errors.Is(err, oauth.ErrBusy) {
+		log.Printf("[oauth] %s: %v", c.Param("provider"), err)
+		msg = "第三方登录失败"
+	}
+	c.Redirect(http.StatusFound, "/login#oauth_error="+url.QueryEscape(msg))
+}
+
+// Callback 公开接口 — 第三方授权回调
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	provider := c.Param("provider")
+	// state 只能使用一次，无论成败都清除
+	browserState, _ := c.Cookie(utils.OAuthStateCookieName)
+	utils.ClearOAuthStateCookie(c, h.Cfg)
+	if e := c.Query("error"); e != "" {
+		h.redirectError(c, errors.New("已取消授权"))
+		return
+	}
+
+	identity, linkUserID, err := h.OAuth.Complete(c.Request.Context(), provider, c.Query("state"), browserState, c.Query("code"))
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+
+	var existing models.UserIdentity
+	found := h.DB.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&existing).Error == nil
+
+	// 绑定到当前登录用户
+	if linkUserID != 0 {
+		if found && existing.UserID != linkUserID {
+			h.redirectError(c, errors.New("该第三方账号已绑定其他用户"))
+			return
+		}
+		if !found {
+			h.DB.Create(&models.UserIdentity{
+				UserID:   linkUserID,
+				Provider: identity.Provider,
+				Subject:  identity.Subject,
+				Username: identity.Username,
+				Email:    identity.Email,
+			})
+		}
+		c.Redirect(http.StatusFound, "/login#oauth_linked="+url.QueryEscape(provider))
+		return
+	}
+
+	var user models.User
+	if found {
+		if err := h.DB.First(&user, existing.UserID).Error; err != nil {
+			h.redirectError(c, errors.New("用户不存在"))
+			return
+		}
+	} else {
+		// 不按邮箱自动合并已有账号，避免第三方邮箱未验证导致的账号接管
+		created, err := h.createUser(identity)
+		if err != nil {
+			h.redirectError(c, err)
+			return
+		}
+		user = *created
+	}
+
+	if s := utils.ActiveSanction(h.DB, user.ID, utils.SanctionBan); s != nil {
+		h.redirectError(c, errors.New("账号已被封禁: "+s.Reason))
+		return
+	}
+
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, h.Cfg.JWTSecret, h.Cfg.JWTExpiry)
+	if err != nil {
+		h.redirectError(c, err)
+		return
+	}
+	if h.Cfg.CookieAuth {
+		if err := utils.SetSessionCookies(c, h.Cfg, token); err != nil {
+			h.redirectError(c, err)
+			return
+		}
+		c.Redirect(http.StatusFound, "/login#oauth_session=1")
+		return
+	}
+	c.Redirect(http.StatusFound, "/login#oauth_token="+url.QueryEscape(token))
+}
+
+// createUser 为首次登录的第三方账号创建站内用户，密码留空表示尚未设置
+func (h *OAuthHandler) createUser(identity *oauth.Identity) (*models.User, error) {
+	base := strings.TrimSpace(identity.Username)
+	if utf8.RuneCountInString(base) < 2 {
+		base = identity.Provider + "_" + identity.Subject
+	}
+	if utf8.RuneCountInString(base) > 28 {
+		base = string([]rune(base)[:28])
+	}
+
+	username := base
+	for i := 2; ; i++ {
+		var count int64
+		h.DB.Model(&models.User{}).Where("username = ?", username).Count(&count)
+		if count == 0 {
+			break
+		}
+		if i > 999 {
+			return nil, errors.New("无法生成可用的用户名")
+		}
+		username = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	user := models.User{
+		Username:  username,
+		Email:     identity.Email,
+		AvatarURL: identity.AvatarURL,
+		Role:      "user",
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Username: identity.Username,
+			Email:    identity.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListIdentities 登录用户 — 已绑定的第三方账号
+func (h *OAuthHandler) ListIdentities(c *gin.Context) {
+	var items []models.UserIdentity
+	h.DB.Where("user_id = ?", c.GetUint("user_id")).Order("id ASC").Find(&items)
+	c.JSON(http.StatusOK, items)
+}
+
+// Unlink 登录用户 — 解除第三方账号绑定，未设置密码时不能解除最后一个
+func (h *OAuthHandler) Unlink(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	provider := c.Param("provider")
+
+	var identity models.UserIdentity
+	if err := h.DB.Where("user_id = ? AND provider = ?", userID, provider).First(&identity).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未绑定该登录方式"})
+		return
+	}
+
+	var user models.User
+	h.DB.First(&user, userID)
+	var count int64
+	h.DB.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count)
+	if user.Password == "" && count <= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先设置密码再解除最后一个登录方式"})
+		return
+	}
+
+	h.DB.Delete(&identity)
+	c.JSON(http.StatusOK, gin.H{"message": "已解除绑定"})
+}
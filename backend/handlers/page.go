@@ -3,7 +3,9 @@ package handlers
 import (
 	"net/http"
 
+	"hxzd-server/markdown"
 	"hxzd-server/models"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -43,19 +45,34 @@ func (h *PageHandler) UpdatePage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
+	if contentTooLong(c, req.Content, maxPageContentBytes) {
+		return
+	}
 
 	var page models.Page
+	var before interface{}
 	result := h.DB.Where("slug = ?", slug).First(&page)
 	if result.RowsAffected == 0 {
-		page = models.Page{Slug: slug, Title: req.Title, Content: req.Content}
+		page = models.Page{
+			Slug:          slug,
+			Title:         req.Title,
+			Content:       req.Content,
+			ContentHTML:   markdown.Render(req.Content),
+			RenderVersion: markdown.Version,
+		}
 		h.DB.Create(&page)
 	} else {
+		before = page
 		h.DB.Model(&page).Updates(map[string]interface{}{
-			"title":   req.Title,
-			"content": req.Content,
+			"title":          req.Title,
+			"content":        req.Content,
+			"content_html":   markdown.Render(req.Content),
+			"render_version": markdown.Version,
 		})
 	}
 
 	h.DB.Where("slug = ?", slug).First(&page)
+	syncAttachments(h.DB, attachmentTargetPage, page.ID, page.Content, c.GetUint("user_id"))
+	utils.RecordAudit(h.DB, c, "page.update", "page", page.Slug, before, page)
 	c.JSON(http.StatusOK, page)
 }
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/notify"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 举报目标类型
+const (
+	reportTargetPost    = "post"
+	reportTargetComment = "comment"
+	reportTargetUser    = "user"
+)
+
+// 举报状态
+const (
+	reportPending   = "pending"
+	reportResolved  = "resolved"
+	reportDismissed = "dismissed"
+)
+
+// 审核处理方式
+const (
+	reportActionDismiss = "dismiss"
+	reportActionHide    = "hide"
+	reportActionDelete  = "delete"
+	reportActionWarn    = "warn"
+	reportActionBan     = "ban"
+)
+
+// reportReasons 可选的举报原因
+var reportReasons = []struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}{
+	{"spam", "垃圾广告"},
+	{"abuse", "辱骂或人身攻击"},
+	{"cheating", "外挂或作弊"},
+	{"illegal", "违法违规内容"},
+	{"offtopic", "与主题无关"},
+	{"other", "其他"},
+}
+
+func reportReasonLabel(key string) (string, bool) {
+	for _, r := range reportReasons {
+		if r.Key == key {
+			return r.Label, true
+		}
+	}
+	return "", false
+}
+
+type ReportHandler struct {
+	DB       *gorm.DB
+	Perms    *utils.PermissionStore
+	Notifier *notify.Notifier
+}
+
+func NewReportHandler(db *gorm.DB, perms *utils.PermissionStore, notifier *notify.Notifier) *ReportHandler {
+	return &ReportHandler{DB: db, Perms: perms, Notifier: notifier}
+}
+
+// describeTarget 读取被举报对象的摘要，对象已不存在时 Exists 为 false
+func (h *ReportHandler) describeTarget(targetType string, targetID uint) *models.ReportTarget {
+	target := &models.ReportTarget{}
+	switch targetType {
+	case reportTargetPost:
+		var post models.ForumPost
+		if h.DB.Preload("Author").First(&post, targetID).Error != nil {
+			return target
+		}
+		target.Exists = true
+		target.Title = post.Title
+		target.Excerpt = notify.Excerpt(post.Content)
+		target.AuthorID = post.AuthorID
+		target.AuthorName = post.Author.Username
+		target.IsHidden = post.IsHidden
+		target.Link = postLink(post.ID)
+	case reportTargetComment:
+		var comment models.ForumComment
+		if h.DB.Preload("Author").First(&comment, targetID).Error != nil || comment.IsDeleted {
+			return target
+		}
+		var post models.ForumPost
+		h.DB.Select("id", "title").First(&post, comment.PostID)
+		target.Exists = true
+		target.Title = post.Title
+		target.Excerpt = notify.Excerpt(comment.Content)
+		target.AuthorID = comment.AuthorID
+		target.AuthorName = comment.Author.Username
+		target.IsHidden = comment.IsHidden
+		target.Link = commentLink(comment.PostID, comment.ID)
+	case reportTargetUser:
+		var user models.User
+		if h.DB.First(&user, targetID).Error != nil {
+			return target
+		}
+		target.Exists = true
+		target.Title = user.Username
+		target.AuthorID = user.ID
+		target.AuthorName = user.Username
+	}
+	return target
+}
+
+// pendingReports 目标上待处理的举报数
+func (h *ReportHandler) pendingReports(targetType string, targetID uint) int64 {
+	var count int64
+	h.DB.Model(&models.ContentReport{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, reportPending).
+		Count(&count)
+	return count
+}
+
+// setHidden 隐藏或恢复帖子、评论
+func (h *ReportHandler) setHidden(targetType string, targetID uint, hidden bool) {
+	switch targetType {
+	case reportTargetPost:
+		h.DB.Model(&models.ForumPost{}).Where("id = ?", targetID).UpdateColumn("is_hidden", hidden)
+		refreshPostTagCounts(h.DB, targetID)
+	case reportTargetComment:
+		h.DB.Model(&models.ForumComment{}).Where("id = ?", targetID).UpdateColumn("is_hidden", hidden)
+	}
+}
+
+// readableTarget 举报人能否看到被举报的帖子或评论：所在分类可读，且内容未被隐藏（版主除外）
+func (h *ReportHandler) readableTarget(c *gin.Context, targetType string, targetID uint) bool {
+	role := currentRole(c)
+	moderator := h.Perms.Has(role, utils.PermForumModerate)
+	var postID uint
+	switch targetType {
+	case reportTargetPost:
+		postID = targetID
+	case reportTargetComment:
+		var comment models.ForumComment
+		if h.DB.Select("id", "post_id", "is_hidden").First(&comment, targetID).Error != nil {
+			return false
+		}
+		if comment.IsHidden && !moderator {
+			return false
+		}
+		postID = comment.PostID
+	default:
+		return true
+	}
+
+	var post models.ForumPost
+	if h.DB.Select("id", "category", "is_hidden").First(&post, postID).Error != nil {
+		return false
+	}
+	if post.IsHidden && !moderator {
+		return false
+	}
+	var cat models.ForumCategory
+	return h.DB.Where("slug = ?", post.Category).First(&cat).Error != nil || canReadCategory(h.Perms, role, &cat)
+}
+
+// Reasons 可选的举报原因
+func (h *ReportHandler) Reasons(c *gin.Context) {
+	c.JSON(http.StatusOK, reportReasons)
+}
+
+// Create 举报帖子、评论或用户，达到 report_auto_hide_threshold 时自动隐藏内容
+func (h *ReportHandler) Create(c *gin.Context) {
+	var req struct {
+		TargetType string `json:"target_type" binding:"required,oneof=post comment user"`
+		TargetID   uint   `json:"target_id" binding:"required"`
+		Reason     string `json:"reason" binding:"required"`
+		Detail     string `json:"detail" binding:"max=512"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if _, ok := reportReasonLabel(req.Reason); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "举报原因无效"})
+		return
+	}
+
+	target := h.describeTarget(req.TargetType, req.TargetID)
+	// 看不到的内容按不存在处理，避免借举报探测受限分类或已隐藏的内容
+	if !target.Exists || !h.readableTarget(c, req.TargetType, req.TargetID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "举报对象不存在"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if target.AuthorID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能举报自己"})
+		return
+	}
+
+	var exists int64
+	h.DB.Model(&models.ContentReport{}).
+		Where("target_type = ? AND target_id = ? AND reporter_id = ? AND status = ?", req.TargetType, req.TargetID, userID, reportPending).
+		Count(&exists)
+	if exists > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "你已举报过该内容，请等待处理"})
+		return
+	}
+
+	report := models.ContentReport{
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		ReporterID: userID,
+		Reason:     req.Reason,
+		Detail:     req.Detail,
+		Status:     reportPending,
+	}
+	h.DB.Create(&report)
+
+	threshold, _ := strconv.Atoi(utils.GetSetting(h.DB, "report_auto_hide_threshold", "0"))
+	if threshold > 0 && req.TargetType != reportTargetUser && !target.IsHidden &&
+		h.pendingReports(req.TargetType, req.TargetID) >= int64(threshold) {
+		h.setHidden(req.TargetType, req.TargetID, true)
+		h.DB.Model(&models.ContentReport{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", req.TargetType, req.TargetID, reportPending).
+			UpdateColumn("auto_hidden", true)
+		utils.RecordAudit(h.DB, c, "report.auto_hide", req.TargetType, req.TargetID, nil, gin.H{"is_hidden": true})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "举报已提交，感谢反馈"})
+}
+
+// List 管理员 — 审核队列，默认只看待处理，支持 status / target_type / reason 过滤
+func (h *ReportHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	query := h.DB.Model(&models.ContentReport{})
+	if status := c.DefaultQuery("status", reportPending); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if v := c.Query("target_type"); v != "" {
+		query = query.Where("target_type = ?", v)
+	}
+	if v := c.Query("reason"); v != "" {
+		query = query.Where("reason = ?", v)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.ContentReport
+	query.Preload("Reporter").Preload("HandledBy").
+		Order("created_at DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&items)
+
+	// 同一目标可能被多次举报，只查一次
+	targets := map[string]*models.ReportTarget{}
+	for i := range items {
+		key := fmt.Sprintf("%s-%d", items[i].TargetType, items[i].TargetID)
+		if targets[key] == nil {
+			targets[key] = h.describeTarget(items[i].TargetType, items[i].TargetID)
+			targets[key].Reports = h.pendingReports(items[i].TargetType, items[i].TargetID)
+		}
+		items[i].Target = targets[key]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": items,
+		"total":   total,
+		"page":    page,
+		"size":    size,
+	})
+}
+
+// Resolve 管理员 — 处理举报，结果同时应用到该目标的全部待处理举报
+func (h *ReportHandler) Resolve(c *gin.Context) {
+	var req struct {
+		Action   string `json:"action" binding:"required,oneof=dismiss hide delete warn ban"`
+		Note     string `json:"note" binding:"max=512"`
+		Duration string `json:"duration"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	var report models.ContentReport
+	if err := h.DB.First(&report, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "举报不存在"})
+		return
+	}
+	if report.Status != reportPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该举报已处理"})
+		return
+	}
+
+	target := h.describeTarget(report.TargetType, report.TargetID)
+	if report.TargetType == reportTargetUser && (req.Action == reportActionHide || req.Action == reportActionDelete) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该操作不适用于用户举报"})
+		return
+	}
+
+	var author models.User
+	if req.Action == reportActionWarn || req.Action == reportActionBan {
+		if !target.Exists || h.DB.First(&author, target.AuthorID).Error != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "被举报的内容已不存在，无法确定作者"})
+			return
+		}
+		if author.ID == c.GetUint("user_id") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能处罚自己"})
+			return
+		}
+	}
+
+	reason, _ := reportReasonLabel(report.Reason)
+	if req.Note != "" {
+		reason = req.Note
+	}
+
+	switch req.Action {
+	case reportActionDismiss:
+		// 驳回时只撤销自动隐藏，版主手动隐藏的内容保持隐藏
+		var autoHidden int64
+		h.DB.Model(&models.ContentReport{}).
+			Where("target_type = ? AND target_id = ? AND status = ? AND auto_hidden = ?", report.TargetType, report.TargetID, reportPending, true).
+			Count(&autoHidden)
+		if target.IsHidden && autoHidden > 0 {
+			h.setHidden(report.TargetType, report.TargetID, false)
+		}
+	case reportActionHide:
+		if target.Exists {
+			h.setHidden(report.TargetType, report.TargetID, true)
+			h.notifyAuthor(c, target, "你的内容因被举报已被隐藏："+target.Title, reason)
+		}
+	case reportActionDelete:
+		if !target.Exists {
+			break
+		}
+		if report.TargetType == reportTargetPost {
+			var post models.ForumPost
+			h.DB.First(&post, report.TargetID)
+			removePost(h.DB, &post, c.GetUint("user_id"), reason)
+		} else {
+			var comment models.ForumComment
+			h.DB.First(&comment, report.TargetID)
+			removeComment(h.DB, &comment, c.GetUint("user_id"), reason)
+		}
+		target.Link = ""
+		h.notifyAuthor(c, target, "你的内容因被举报已被删除："+target.Title, reason)
+	case reportActionWarn:
+		h.notifyAuthor(c, target, "你因发布违规内容收到一次警告", reason)
+	case reportActionBan:
+		if !h.Perms.Has(currentRole(c), utils.PermUsersSanction) || !h.Perms.Covers(currentRole(c), author.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限封禁该用户"})
+			return
+		}
+		expiresAt, ok := parseSanctionDuration(c, req.Duration)
+		if !ok {
+			return
+		}
+		issueSanction(h.DB, h.Notifier, c, author.ID, utils.SanctionBan, reason, expiresAt)
+	}
+
+	status := reportResolved
+	if req.Action == reportActionDismiss {
+		status = reportDismissed
+	}
+	var reporterIDs []uint
+	h.DB.Model(&models.ContentReport{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, reportPending).
+		Pluck("reporter_id", &reporterIDs)
+	h.DB.Model(&models.ContentReport{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, reportPending).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"action":        req.Action,
+			"note":          req.Note,
+			"handled_by_id": c.GetUint("user_id"),
+			"handled_at":    time.Now(),
+		})
+	utils.RecordAudit(h.DB, c, "report."+req.Action, report.TargetType, report.TargetID, nil, gin.H{
+		"reports": len(reporterIDs),
+		"note":    req.Note,
+	})
+
+	title := "你举报的内容已处理"
+	if status == reportDismissed {
+		title = "你举报的内容经审核未发现违规"
+	}
+	for _, id := range reporterIDs {
+		msg := actorNotification(c, notify.TypeModeration)
+		msg.UserID = id
+		msg.Title = title
+		msg.Body = target.Title
+		h.Notifier.Send(msg)
+	}
+
+	h.DB.Preload("Reporter").Preload("HandledBy").First(&report, report.ID)
+	c.JSON(http.StatusOK, report)
+}
+
+// notifyAuthor 通知被举报内容的作者
+func (h *ReportHandler) notifyAuthor(c *gin.Context, target *models.ReportTarget, title, body string) {
+	msg := actorNotification(c, notify.TypeModeration)
+	msg.UserID = target.AuthorID
+	msg.Title = title
+	msg.Body = body
+	msg.Link = target.Link
+	h.Notifier.Send(msg)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,19}$`)
+
+type RoleHandler struct {
+	DB    *gorm.DB
+	Perms *utils.PermissionStore
+}
+
+func NewRoleHandler(db *gorm.DB, perms *utils.PermissionStore) *RoleHandler {
+	return &RoleHandler{DB: db, Perms: perms}
+}
+
+func validatePermissions(perms []string) bool {
+	for _, p := range perms {
+		if !utils.IsValidPermission(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListPermissions 管理员 — 可分配的权限列表
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, utils.AllPermissions)
+}
+
+// ListRoles 管理员 — 角色列表
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	var roles []models.Role
+	h.DB.Order("is_system DESC, id ASC").Find(&roles)
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole 管理员 — 新建自定义角色
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req struct {
+		Name        string   `json:"name" binding:"required"`
+		Label       string   `json:"label"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色名只能包含小写字母、数字和下划线"})
+		return
+	}
+	if req.Permissions == nil {
+		req.Permissions = []string{}
+	}
+	if !validatePermissions(req.Permissions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "包含未知权限"})
+		return
+	}
+	if !h.Perms.Grants(currentRole(c), req.Permissions) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能授予超出自身权限的权限"})
+		return
+	}
+
+	var existing models.Role
+	if h.DB.Where("name = ?", req.Name).First(&existing).Error == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "角色已存在"})
+		return
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Label:       req.Label,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	h.DB.Create(&role)
+	h.Perms.Invalidate()
+	utils.RecordAudit(h.DB, c, "role.create", "role", role.ID, nil, role)
+	c.JSON(http.StatusOK, role)
+}
+
+// UpdateRole 管理员 — 更新角色说明与权限，内置角色不可修改权限
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id := c.Param("id")
+	var role models.Role
+	if err := h.DB.First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	// 不能修改自己所属的角色，也不能修改权限高于自己的角色
+	if role.Name == currentRole(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能修改自己所属的角色"})
+		return
+	}
+	if !h.Perms.Covers(currentRole(c), role.Name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能修改超出自身权限的角色"})
+		return
+	}
+
+	var req struct {
+		Label       *string  `json:"label"`
+		Description *string  `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	before := role
+	if req.Label != nil {
+		role.Label = *req.Label
+	}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		if role.IsSystem {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "内置角色的权限不可修改"})
+			return
+		}
+		if !validatePermissions(req.Permissions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "包含未知权限"})
+			return
+		}
+		if !h.Perms.Grants(currentRole(c), req.Permissions) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "不能授予超出自身权限的权限"})
+			return
+		}
+		role.Permissions = req.Permissions
+	}
+
+	h.DB.Save(&role)
+	h.Perms.Invalidate()
+	utils.RecordAudit(h.DB, c, "role.update", "role", role.ID, before, role)
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole 管理员 — 删除未被使用的自定义角色
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id := c.Param("id")
+	var role models.Role
+	if err := h.DB.First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "内置角色不可删除"})
+		return
+	}
+	if !h.Perms.Covers(currentRole(c), role.Name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能删除超出自身权限的角色"})
+		return
+	}
+
+	var count int64
+	h.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "仍有用户使用该角色"})
+		return
+	}
+
+	h.DB.Delete(&role)
+	h.Perms.Invalidate()
+	utils.RecordAudit(h.DB, c, "role.delete", "role", role.ID, role, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
+}
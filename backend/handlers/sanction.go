@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"hxzd-server/models"
+	"hxzd-server/notify"
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SanctionHandler struct {
+	DB       *gorm.DB
+	Perms    *utils.PermissionStore
+	Notifier *notify.Notifier
+}
+
+func NewSanctionHandler(db *gorm.DB, perms *utils.PermissionStore, notifier *notify.Notifier) *SanctionHandler {
+	return &SanctionHandler{DB: db, Perms: perms, Notifier: notifier}
+}
+
+// parseSanctionDuration 解析处罚时长，为空表示永久；格式错误时写入 400 响应
+func parseSanctionDuration(c *gin.Context, duration string) (*time.Time, bool) {
+	if duration == "" {
+		return nil, true
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil || d <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时长格式错误，例如 72h"})
+		return nil, false
+	}
+	t := time.Now().Add(d)
+	return &t, true
+}
+
+// sanctionLabel 处罚类型的中文名称
+func sanctionLabel(kind string) string {
+	if kind == utils.SanctionMute {
+		return "禁言"
+	}
+	return "封禁"
+}
+
+// ListSanctions 管理员 — 处罚记录，支持 user_id / type / active 过滤
+func (h *SanctionHandler) ListSanctions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	query := h.DB.Model(&models.UserSanction{})
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if kind := c.Query("type"); kind != "" {
+		query = query.Where("type = ?", kind)
+	}
+	if c.Query("active") == "1" {
+		query = query.Where("lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var items []models.UserSanction
+	query.Preload("User").Preload("IssuedBy").
+		Order("created_at DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&items)
+
+	c.JSON(http.StatusOK, gin.H{
+		"sanctions": items,
+		"total":     total,
+		"page":      page,
+		"size":      size,
+	})
+}
+
+// CreateSanction 管理员 — 封禁或禁言用户，duration 为空表示永久
+func (h *SanctionHandler) CreateSanction(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Type     string `json:"type" binding:"required,oneof=ban mute"`
+		Reason   string `json:"reason" binding:"required,max=512"`
+		Duration string `json:"duration"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	expiresAt, ok := parseSanctionDuration(c, req.Duration)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := h.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	currentID := c.GetUint("user_id")
+	if user.ID == currentID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能处罚自己"})
+		return
+	}
+	if !h.Perms.Covers(currentRole(c), user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+		return
+	}
+
+	item := issueSanction(h.DB, h.Notifier, c, user.ID, req.Type, req.Reason, expiresAt)
+	c.JSON(http.StatusOK, item)
+}
+
+// issueSanction 写入处罚、记录审计并通知被处罚用户
+func issueSanction(db *gorm.DB, notifier *notify.Notifier, c *gin.Context, userID uint, kind, reason string, expiresAt *time.Time) models.UserSanction {
+	item := models.UserSanction{
+		UserID:     userID,
+		Type:       kind,
+		Reason:     reason,
+		ExpiresAt:  expiresAt,
+		IssuedByID: c.GetUint("user_id"),
+	}
+	db.Create(&item)
+	db.Preload("User").Preload("IssuedBy").First(&item, item.ID)
+	utils.RecordAudit(db, c, "sanction.create", "user", userID, nil, item)
+
+	msg := actorNotification(c, notify.TypeModeration)
+	msg.UserID = userID
+	msg.Title = "你已被" + sanctionLabel(kind)
+	if expiresAt != nil {
+		msg.Title += "，到期时间 " + expiresAt.Format("2006-01-02 15:04")
+	}
+	msg.Body = reason
+	notifier.Send(msg)
+	return item
+}
+
+// LiftSanction 管理员 — 提前解除处罚
+func (h *SanctionHandler) LiftSanction(c *gin.Context) {
+	id := c.Param("id")
+	var item models.UserSanction
+	if err := h.DB.First(&item, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+		return
+	}
+	if item.LiftedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该处罚已解除"})
+		return
+	}
+
+	currentID := c.GetUint("user_id")
+	if item.UserID == currentID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能解除自己的处罚"})
+		return
+	}
+	var user models.User
+	if err := h.DB.First(&user, item.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if !h.Perms.Covers(currentRole(c), user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+		return
+	}
+
+	before := item
+	h.DB.Model(&item).Updates(map[string]interface{}{
+		"lifted_at":    time.Now(),
+		"lifted_by_id": currentID,
+	})
+	h.DB.Preload("User").Preload("IssuedBy").First(&item, item.ID)
+	utils.RecordAudit(h.DB, c, "sanction.lift", "user", item.UserID, before, item)
+
+	msg := actorNotification(c, notify.TypeModeration)
+	msg.UserID = item.UserID
+	msg.Title = "你的" + sanctionLabel(item.Type) + "已被解除"
+	h.Notifier.Send(msg)
+	c.JSON(http.StatusOK, item)
+}
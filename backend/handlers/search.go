@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"hxzd-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	searchMaxTerms   = 8
+	searchMaxWindow  = 500
+	snippetRadius    = 40
+	snippetMaxLength = 120
+)
+
+var (
+	htmlTagPattern    = regexp.MustCompile(`<[^>]*>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// searchSource 可搜索的内容类型
+type searchSource struct {
+	Type    string
+	Table   string
+	Columns string // FULLTEXT 索引列
+	Select  string
+	Joins   string
+	Visible string // 可公开展示的条件，排除被删除或隐藏的内容
+	Forum   bool   // 受论坛分类阅读权限限制
+}
+
+var searchSources = []searchSource{
+	{
+		Type:    "post",
+		Table:   "forum_posts",
+		Columns: "forum_posts.title, forum_posts.content",
+		Select:  "forum_posts.id, forum_posts.title, forum_posts.content, forum_posts.id AS post_id, '' AS slug, forum_posts.created_at",
+		Visible: "forum_posts.deleted_at IS NULL AND forum_posts.is_hidden = false",
+		Forum:   true,
+	},
+	{
+		Type:    "comment",
+		Table:   "forum_comments",
+		Columns: "forum_comments.content",
+		Select:  "forum_comments.id, forum_posts.title, forum_comments.content, forum_comments.post_id, '' AS slug, forum_comments.created_at",
+		Joins:   "JOIN forum_posts ON forum_posts.id = forum_comments.post_id",
+		Visible: "forum_comments.is_deleted = false AND forum_comments.is_hidden = false AND forum_posts.deleted_at IS NULL AND forum_posts.is_hidden = false",
+		Forum:   true,
+	},
+	{
+		Type:    "announcement",
+		Table:   "announcements",
+		Columns: "announcements.title, announcements.content",
+		Select:  "announcements.id, announcements.title, announcements.content, 0 AS post_id, '' AS slug, announcements.created_at",
+	},
+	{
+		Type:    "page",
+		Table:   "pages",
+		Columns: "pages.title, pages.content",
+		Select:  "pages.id, pages.title, pages.content, 0 AS post_id, pages.slug, pages.updated_at AS created_at",
+	},
+}
+
+type searchRow struct {
+	ID        uint
+	Title     string
+	Content   string
+	PostID    uint
+	Slug      string
+	Score     float64
+	CreatedAt time.Time
+}
+
+// SearchResult 单条搜索结果，Snippet 为已转义并用 <mark> 高亮的 HTML
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	PostID    uint      `json:"post_id,omitempty"`
+	Slug      string    `json:"slug,omitempty"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchHandler struct {
+	DB       *gorm.DB
+	Perms    *utils.PermissionStore
+	fulltext bool
+}
+
+func NewSearchHandler(db *gorm.DB, perms *utils.PermissionStore) *SearchHandler {
+	return &SearchHandler{DB: db, Perms: perms, fulltext: db.Dialector.Name() == "mysql"}
+}
+
+// parseSearchTerms 拆分关键词并去掉布尔模式运算符
+func parseSearchTerms(q string) []string {
+	var terms []string
+	for _, f := range strings.Fields(q) {
+		f = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`+-<>()~*"@%_\`, r) {
+				return -1
+			}
+			return r
+		}, f)
+		if f != "" {
+			terms = append(terms, f)
+		}
+		if len(terms) == searchMaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// booleanQuery 每个关键词都必须出现，按短语匹配以适配 ngram 分词
+func booleanQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = `+"` + t + `"`
+	}
+	return strings.Join(parts, " ")
+}
+
+func (h *SearchHandler) baseQuery(src searchSource, terms, hidden []string) *gorm.DB {
+	query := h.DB.Table(src.Table)
+	if src.Joins != "" {
+		query = query.Joins(src.Joins)
+	}
+	if src.Visible != "" {
+		query = query.Where(src.Visible)
+	}
+	if src.Forum && len(hidden) > 0 {
+		query = query.Where("forum_posts.category NOT IN ?", hidden)
+	}
+	if h.fulltext {
+		return query.Where("MATCH("+src.Columns+") AGAINST (? IN BOOLEAN MODE)", booleanQuery(terms))
+	}
+	// 非 MySQL 回退为 LIKE 匹配
+	cols := strings.Split(src.Columns, ", ")
+	for _, t := range terms {
+		conds := make([]string, len(cols))
+		args := make([]interface{}, len(cols))
+		for i, col := range cols {
+			conds[i] = col + " LIKE ?"
+			args[i] = "%" + t + "%"
+		}
+		query = query.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+	return query
+}
+
+func (h *SearchHandler) searchSource(src searchSource, terms, hidden []string, limit int) ([]searchRow, int64) {
+	var total int64
+	h.baseQuery(src, terms, hidden).Count(&total)
+	if total == 0 {
+		return nil, 0
+	}
+
+	var rows []searchRow
+	query := h.baseQuery(src, terms, hidden)
+	if h.fulltext {
+		query.Select(src.Select+", MATCH("+src.Columns+") AGAINST (? IN BOOLEAN MODE) AS score", booleanQuery(terms)).
+			Order("score DESC").Limit(limit).Scan(&rows)
+	} else {
+		query.Select(src.Select + ", 0 AS score").Order("created_at DESC").Limit(limit).Scan(&rows)
+		for i := range rows {
+			rows[i].Score = likeScore(rows[i], terms)
+		}
+	}
+	return rows, total
+}
+
+// likeScore 回退模式下的简单相关度：标题命中权重更高
+func likeScore(row searchRow, terms []string) float64 {
+	title, content := strings.ToLower(row.Title), strings.ToLower(row.Content)
+	score := 0.0
+	for _, t := range terms {
+		t = strings.ToLower(t)
+		score += 3*float64(strings.Count(title, t)) + float64(strings.Count(content, t))
+	}
+	return score
+}
+
+// plainText 去除 HTML 标签并合并空白
+func plainText(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(s, " "))
+}
+
+func lowerRunes(rs []rune) []rune {
+	out := make([]rune, len(rs))
+	for i, r := range rs {
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+func indexRunes(haystack, needle []rune, from int) int {
+	for i := from; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// highlight 截取第一个命中附近的片段，转义后用 <mark> 标出所有关键词
+func highlight(content string, terms []string) string {
+	text := []rune(plainText(content))
+	lower := lowerRunes(text)
+	needles := make([][]rune, 0, len(terms))
+	for _, t := range terms {
+		needles = append(needles, lowerRunes([]rune(t)))
+	}
+
+	first := -1
+	for _, n := range needles {
+		if i := indexRunes(lower, n, 0); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	start := 0
+	if first > snippetRadius {
+		start = first - snippetRadius
+	}
+	end := start + snippetMaxLength
+	if end > len(text) {
+		end = len(text)
+	}
+
+	// 标记命中区间
+	marked := make([]bool, end-start)
+	for _, n := range needles {
+		if len(n) == 0 {
+			continue
+		}
+		for i := indexRunes(lower[:end], n, start); i >= 0; i = indexRunes(lower[:end], n, i+len(n)) {
+			for j := i; j < i+len(n) && j < end; j++ {
+				marked[j-start] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marked[i-start] && !inMark {
+			b.WriteString("<mark>")
+			inMark = true
+		} else if !marked[i-start] && inMark {
+			b.WriteString("</mark>")
+			inMark = false
+		}
+		b.WriteString(html.EscapeString(string(text[i])))
+	}
+	if inMark {
+		b.WriteString("</mark>")
+	}
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// Search 公开接口 — 站内搜索，type 可为 post,comment,announcement,page 的组合
+func (h *SearchHandler) Search(c *gin.Context) {
+	terms := parseSearchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入搜索关键词"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 50 {
+		size = 20
+	}
+	limit := page * size
+	if limit > searchMaxWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "页码过大，请细化关键词"})
+		return
+	}
+
+	types := map[string]bool{}
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+
+	hidden := hiddenCategories(h.DB, h.Perms, currentRole(c))
+	results := []SearchResult{}
+	counts := map[string]int64{}
+	var total int64
+	for _, src := range searchSources {
+		if len(types) > 0 && !types[src.Type] {
+			continue
+		}
+		rows, n := h.searchSource(src, terms, hidden, limit)
+		counts[src.Type] = n
+		total += n
+		for _, r := range rows {
+			results = append(results, SearchResult{
+				Type:      src.Type,
+				ID:        r.ID,
+				Title:     r.Title,
+				Snippet:   highlight(r.Content, terms),
+				PostID:    r.PostID,
+				Slug:      r.Slug,
+				Score:     r.Score,
+				CreatedAt: r.CreatedAt,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	offset := (page - 1) * size
+	if offset > len(results) {
+		offset = len(results)
+	}
+	end := offset + size
+	if end > len(results) {
+		end = len(results)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results[offset:end],
+		"total":   total,
+		"counts":  counts,
+		"page":    page,
+		"size":    size,
+	})
+}
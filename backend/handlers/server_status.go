@@ -12,6 +12,7 @@ import (
 
 	"hxzd-server/config"
 	"hxzd-server/models"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -269,6 +270,7 @@ func (h *ServerStatusHandler) CreateServer(c *gin.Context) {
 		Enabled:    req.Enabled,
 	}
 	h.DB.Create(&srv)
+	utils.RecordAudit(h.DB, c, "server.create", "game_server", srv.ID, nil, srv)
 	go h.refreshAll()
 	c.JSON(http.StatusOK, srv)
 }
@@ -310,18 +312,26 @@ func (h *ServerStatusHandler) UpdateServer(c *gin.Context) {
 		updates["enabled"] = *req.Enabled
 	}
 
+	before := srv
 	h.DB.Model(&srv).Updates(updates)
 	go h.refreshAll()
 	h.DB.First(&srv, id)
+	utils.RecordAudit(h.DB, c, "server.update", "game_server", srv.ID, before, srv)
 	c.JSON(http.StatusOK, srv)
 }
 
 func (h *ServerStatusHandler) DeleteServer(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.DB.Delete(&models.GameServer{}, id).Error; err != nil {
+	var srv models.GameServer
+	if err := h.DB.First(&srv, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "服务器不存在"})
+		return
+	}
+	if err := h.DB.Delete(&srv).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
 		return
 	}
+	utils.RecordAudit(h.DB, c, "server.delete", "game_server", srv.ID, srv, nil)
 	go h.refreshAll()
 	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
 }
@@ -347,6 +357,7 @@ func (h *ServerStatusHandler) UpdateConfig(c *gin.Context) {
 
 	var cfg models.ServerStatusConfig
 	h.DB.First(&cfg)
+	before := cfg
 	updates := map[string]interface{}{}
 	if req.MCServerAddress != "" {
 		updates["mc_server_address"] = req.MCServerAddress
@@ -357,10 +368,12 @@ func (h *ServerStatusHandler) UpdateConfig(c *gin.Context) {
 	updates["embed_url"] = req.EmbedURL
 	h.DB.Model(&cfg).Updates(updates)
 	h.DB.First(&cfg)
+	utils.RecordAudit(h.DB, c, "server_status.config", "server_status_config", cfg.ID, before, cfg)
 	c.JSON(http.StatusOK, cfg)
 }
 
 func (h *ServerStatusHandler) RefreshStatus(c *gin.Context) {
+	utils.RecordAudit(h.DB, c, "server_status.refresh", "server_status_config", "", nil, nil)
 	go h.refreshAll()
 	c.JSON(http.StatusOK, gin.H{"message": "刷新已触发"})
 }
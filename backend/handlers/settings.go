@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"hxzd-server/models"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -40,15 +41,20 @@ func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	before := map[string]string{}
+	after := map[string]string{}
 	for key, value := range req {
 		var setting models.SiteSetting
 		result := h.DB.Where("`key` = ?", key).First(&setting)
 		if result.RowsAffected == 0 {
 			h.DB.Create(&models.SiteSetting{Key: key, Value: value})
 		} else {
+			before[key] = setting.Value
 			h.DB.Model(&setting).Update("value", value)
 		}
+		after[key] = value
 	}
+	utils.RecordAudit(h.DB, c, "settings.update", "settings", "", before, after)
 
 	c.JSON(http.StatusOK, gin.H{"message": "设置已更新"})
 }
@@ -4,18 +4,26 @@ import (
 	"net/http"
 
 	"hxzd-server/models"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Perms  *utils.PermissionStore
+	Hasher utils.PasswordHasher
+	Policy *utils.PasswordPolicy
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{DB: db}
+func NewUserHandler(db *gorm.DB, perms *utils.PermissionStore, hasher utils.PasswordHasher, policy *utils.PasswordPolicy) *UserHandler {
+	return &UserHandler{DB: db, Perms: perms, Hasher: hasher, Policy: policy}
+}
+
+// canManage 操作者必须覆盖目标用户当前角色的全部权限
+func (h *UserHandler) canManage(c *gin.Context, target *models.User) bool {
+	return h.Perms.Covers(currentRole(c), target.Role)
 }
 
 func (h *UserHandler) ListUsers(c *gin.Context) {
@@ -33,25 +41,61 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
-	if req.Role != "admin" && req.Role != "user" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "角色只能是 admin 或 user"})
+	if !h.Perms.RoleExists(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在"})
 		return
 	}
-	h.DB.Model(&models.User{}).Where("id = ?", id).Update("role", req.Role)
+
+	var user models.User
+	if err := h.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if !h.canManage(c, &user) || !h.Perms.Covers(currentRole(c), req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能分配超出自身权限的角色"})
+		return
+	}
+	before := user
+	h.DB.Model(&user).Update("role", req.Role)
+	h.DB.First(&user, user.ID)
+	utils.RecordAudit(h.DB, c, "user.role", "user", user.ID, before, user)
 	c.JSON(http.StatusOK, gin.H{"message": "角色已更新"})
 }
 
 func (h *UserHandler) ResetPassword(c *gin.Context) {
 	id := c.Param("id")
 	var req struct {
-		NewPassword string `json:"new_password" binding:"required,min=6"`
+		NewPassword string `json:"new_password" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "密码至少6位"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
-	hash, _ := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
-	h.DB.Model(&models.User{}).Where("id = ?", id).Update("password", string(hash))
+
+	var user models.User
+	if err := h.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if !h.canManage(c, &user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+		return
+	}
+	if err := h.Policy.Check(req.NewPassword, user.Username, user.MinecraftID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash, err := h.Hasher.Hash(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		return
+	}
+	// 管理员设置的临时密码，用户下次登录后必须修改
+	h.DB.Model(&user).Updates(map[string]interface{}{
+		"password":             hash,
+		"must_change_password": true,
+	})
+	utils.RecordAudit(h.DB, c, "user.password_reset", "user", user.ID, nil, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "密码已重置"})
 }
 
@@ -67,6 +111,11 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "不能删除自己"})
 		return
 	}
+	if !h.canManage(c, &user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+		return
+	}
 	h.DB.Delete(&user)
+	utils.RecordAudit(h.DB, c, "user.delete", "user", user.ID, user, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "用户已删除"})
 }
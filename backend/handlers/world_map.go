@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"hxzd-server/models"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -56,6 +57,7 @@ func (h *WorldMapHandler) CreateMap(c *gin.Context) {
 		Enabled:   enabled,
 	}
 	h.DB.Create(&m)
+	utils.RecordAudit(h.DB, c, "world_map.create", "world_map", m.ID, nil, m)
 	c.JSON(http.StatusOK, m)
 }
 
@@ -93,8 +95,10 @@ func (h *WorldMapHandler) UpdateMap(c *gin.Context) {
 		updates["enabled"] = *req.Enabled
 	}
 
+	before := m
 	h.DB.Model(&m).Updates(updates)
 	h.DB.First(&m, id)
+	utils.RecordAudit(h.DB, c, "world_map.update", "world_map", m.ID, before, m)
 	c.JSON(http.StatusOK, m)
 }
 
@@ -107,5 +111,6 @@ func (h *WorldMapHandler) DeleteMap(c *gin.Context) {
 		return
 	}
 	h.DB.Delete(&m)
+	utils.RecordAudit(h.DB, c, "world_map.delete", "world_map", m.ID, m, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "已删除"})
 }
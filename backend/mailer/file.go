@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSink 把邮件写入本地目录（每封一个 .eml 文件），用于开发与测试
+type FileSink struct {
+	Dir  string
+	From string
+}
+
+func NewFileSink(dir, from string) *FileSink {
+	return &FileSink{Dir: dir, From: from}
+}
+
+func (f *FileSink) Send(_ context.Context, msg Message) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	now := time.Now()
+	name := now.Format("20060102-150405.000") + "-" + hex.EncodeToString(suffix) + ".eml"
+	return os.WriteFile(filepath.Join(f.Dir, name), Build(f.From, msg, now), 0o644)
+}
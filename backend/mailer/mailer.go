@@ -0,0 +1,80 @@
+// Package mailer 发送站点邮件；开发环境使用 FileSink 把邮件写成 .eml 文件，便于直接查看
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"hxzd-server/config"
+)
+
+// Message 纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer 邮件发送方式
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New 按 MAIL_DRIVER 创建发送方式，未配置时返回 nil 表示不发送邮件
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case "":
+		return nil, nil
+	case "file":
+		return NewFileSink(cfg.MailFileDir, cfg.MailFrom), nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("mailer: SMTP_HOST is required")
+		}
+		return NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	}
+	return nil, fmt.Errorf("mailer: unknown driver %q", cfg.MailDriver)
+}
+
+// stripNewlines 防止通过收件人或标题注入额外的邮件头
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+}
+
+// domainOf 取发件地址的域名，用于生成 Message-ID
+func domainOf(addr string) string {
+	if i := strings.LastIndexByte(addr, '@'); i >= 0 {
+		return strings.Trim(addr[i+1:], "> ")
+	}
+	return "localhost"
+}
+
+// Build 生成 RFC 5322 格式的邮件内容，正文使用 UTF-8 + base64 编码
+func Build(from string, msg Message, now time.Time) []byte {
+	id := make([]byte, 12)
+	rand.Read(id)
+
+	var b strings.Builder
+	b.WriteString("From: " + stripNewlines(from) + "\r\n")
+	b.WriteString("To: " + stripNewlines(msg.To) + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("utf-8", stripNewlines(msg.Subject)) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Message-ID: <" + hex.EncodeToString(id) + "@" + domainOf(from) + ">\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	body := base64.StdEncoding.EncodeToString([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n")))
+	for len(body) > 76 {
+		b.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	b.WriteString(body + "\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTP 通过 SMTP 服务器发送，服务器支持时自动使用 STARTTLS
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func NewSMTP(host, port, username, password, from string) *SMTP {
+	return &SMTP{Host: host, Port: port, Username: username, Password: password, From: from}
+}
+
+// Send 不支持中途取消，ctx 仅用于在开始前检查是否已超时
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	from := s.From
+	if addr, err := mail.ParseAddress(s.From); err == nil {
+		from = addr.Address
+	}
+	return smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, from, []string{msg.To}, Build(s.From, msg, time.Now()))
+}
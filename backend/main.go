@@ -6,6 +6,7 @@ import (
 	"hxzd-server/config"
 	"hxzd-server/database"
 	"hxzd-server/routes"
+	"hxzd-server/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,18 +15,20 @@ import (
 func main() {
 	cfg := config.Load()
 	db := database.InitDB(cfg)
-	database.SeedDefaults(db)
+	database.SeedDefaults(db, utils.NewPasswordHasher(cfg))
 
 	r := gin.Default()
 
-	// CORS
-	r.Use(cors.New(cors.Config{
-		AllowAllOrigins:  true,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
+	// CORS：仅允许显式配置的来源，同域部署无需开启
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		r.Use(cors.New(cors.Config{
+			AllowOrigins:     cfg.CORSAllowedOrigins,
+			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-CSRF-Token"},
+			ExposeHeaders:    []string{"Content-Length"},
+			AllowCredentials: true,
+		}))
+	}
 
 	routes.SetupRoutes(r, db, cfg)
 
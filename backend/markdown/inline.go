@@ -0,0 +1,343 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	inlineTagPattern = regexp.MustCompile(`^(?:</?[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*\s*/?>|<!--.*?-->)`)
+	autolinkPattern  = regexp.MustCompile(`^<((?:https?|mailto):[^\s<>]+|[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,})>`)
+	bareURLPattern   = regexp.MustCompile(`^https?://[^\s<]+`)
+	entityPattern    = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	coordPattern     = regexp.MustCompile(`^@\[\s*(?:(overworld|nether|end)\s*:\s*)?(-?\d{1,8})\s*,\s*(-?\d{1,8})\s*,\s*(-?\d{1,8})\s*\]`)
+	itemPattern      = regexp.MustCompile(`^\[\[((?:[a-z0-9_.-]+:)?[a-z0-9_./-]+)\]\]`)
+)
+
+var dimensionLabels = map[string]string{
+	"overworld": "主世界",
+	"nether":    "下界",
+	"end":       "末地",
+}
+
+func isPunct(b byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", b) >= 0
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n'
+}
+
+func isWordChar(b byte) bool {
+	return b >= 0x80 || b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// maxLinkLength 链接文本与地址的最大扫描长度，避免病态输入导致平方级耗时
+const maxLinkLength = 2048
+
+// renderInline 解析行内语法，文本一律转义
+func renderInline(s string) string {
+	var out strings.Builder
+	// 某个定界符从前面的位置找不到结束符时，后面的位置同样找不到，记录下来避免重复扫描
+	unmatched := map[string]bool{}
+	delimited := func(i int, delim string) (int, string, bool) {
+		if unmatched[delim] {
+			return 0, "", false
+		}
+		n, inner, ok := parseDelimited(s, i, delim)
+		if !ok && i+len(delim) < len(s) && !isSpace(s[i+len(delim)]) {
+			unmatched[delim] = true
+		}
+		return n, inner, ok
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		rest := s[i:]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			out.WriteString("<br>\n")
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			out.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\n':
+			// 用户内容按换行显示，软换行同样输出 <br>
+			out.WriteString("<br>\n")
+			i++
+			continue
+
+		case c == ' ':
+			// 行尾空格不输出
+			if n := len(rest) - len(strings.TrimLeft(rest, " ")); i+n < len(s) && s[i+n] == '\n' {
+				i += n
+				continue
+			}
+
+		case c == '`':
+			run := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if key := rest[:run]; !unmatched[key] {
+				if n, code, ok := parseCodeSpan(rest); ok {
+					out.WriteString("<code>" + html.EscapeString(code) + "</code>")
+					i += n
+					continue
+				}
+				unmatched[key] = true
+			}
+			out.WriteString(rest[:run])
+			i += run
+			continue
+
+		case c == '<':
+			if m := autolinkPattern.FindStringSubmatch(rest); m != nil {
+				href := m[1]
+				if !strings.Contains(href, ":") {
+					href = "mailto:" + href
+				}
+				out.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+				continue
+			}
+			if m := inlineTagPattern.FindString(rest); m != "" {
+				out.WriteString(m)
+				i += len(m)
+				continue
+			}
+
+		case c == '&':
+			if m := entityPattern.FindString(rest); m != "" {
+				out.WriteString(m)
+				i += len(m)
+				continue
+			}
+
+		case c == 'h' && (i == 0 || !isWordChar(s[i-1])):
+			if m := bareURLPattern.FindString(rest); m != "" {
+				m = strings.TrimRight(m, ".,;:!?'\")")
+				// 保留配对的右括号
+				for strings.Count(m, "(") > strings.Count(m, ")") && len(m) < len(rest) && rest[len(m)] == ')' {
+					m += ")"
+				}
+				out.WriteString(`<a href="` + html.EscapeString(m) + `">` + html.EscapeString(m) + "</a>")
+				i += len(m)
+				continue
+			}
+
+		case c == '@':
+			if m := coordPattern.FindStringSubmatch(rest); m != nil {
+				out.WriteString(renderCoord(m[1], m[2], m[3], m[4]))
+				i += len(m[0])
+				continue
+			}
+
+		case c == '[' || (c == '!' && i+1 < len(s) && s[i+1] == '['):
+			if m := itemPattern.FindStringSubmatch(rest); m != nil {
+				out.WriteString(renderItem(m[1]))
+				i += len(m[0])
+				continue
+			}
+			if n, link, ok := renderLink(rest); ok {
+				out.WriteString(link)
+				i += n
+				continue
+			}
+
+		case c == '|' && strings.HasPrefix(rest, "||") && !unmatched["||"]:
+			end := strings.Index(rest[2:], "||")
+			if end > 0 {
+				out.WriteString(`<span class="spoiler">` + renderInline(rest[2:2+end]) + "</span>")
+				i += end + 4
+				continue
+			}
+			if end < 0 {
+				unmatched["||"] = true
+			}
+
+		case c == '~' && strings.HasPrefix(rest, "~~"):
+			if n, inner, ok := delimited(i, "~~"); ok {
+				out.WriteString("<del>" + renderInline(inner) + "</del>")
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if strings.HasPrefix(rest, strings.Repeat(string(c), 2)) {
+				if n, inner, ok := delimited(i, strings.Repeat(string(c), 2)); ok {
+					out.WriteString("<strong>" + renderInline(inner) + "</strong>")
+					i += n
+					continue
+				}
+			}
+			if n, inner, ok := delimited(i, string(c)); ok {
+				out.WriteString("<em>" + renderInline(inner) + "</em>")
+				i += n
+				continue
+			}
+			run := len(rest) - len(strings.TrimLeft(rest, string(c)))
+			out.WriteString(rest[:run])
+			i += run
+			continue
+		}
+
+		out.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// parseCodeSpan 匹配等长的反引号串
+func parseCodeSpan(s string) (int, string, bool) {
+	run := len(s) - len(strings.TrimLeft(s, "`"))
+	fence := s[:run]
+	for j := run; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			return 0, "", false
+		}
+		k += j
+		end := k + run
+		if end < len(s) && s[end] == '`' {
+			j = end + len(s[end:]) - len(strings.TrimLeft(s[end:], "`"))
+			continue
+		}
+		code := strings.ReplaceAll(s[run:k], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		return end, code, true
+	}
+	return 0, "", false
+}
+
+// parseDelimited 查找成对的强调定界符；开头不能后接空白，结尾不能前接空白，下划线不在词内生效
+func parseDelimited(s string, start int, delim string) (int, string, bool) {
+	open := start + len(delim)
+	if open >= len(s) || isSpace(s[open]) {
+		return 0, "", false
+	}
+	if delim[0] == '_' && start > 0 && isWordChar(s[start-1]) {
+		return 0, "", false
+	}
+	for j := open + 1; j+len(delim) <= len(s); j++ {
+		if s[j] == '`' {
+			if n, _, ok := parseCodeSpan(s[j:]); ok {
+				j += n - 1
+				continue
+			}
+		}
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] != delim[0] {
+			continue
+		}
+		// 只有长度相同的定界符串才能闭合（避免把 ** 拆开）
+		run := len(s[j:]) - len(strings.TrimLeft(s[j:], delim[:1]))
+		if run != len(delim) || isSpace(s[j-1]) {
+			j += run - 1
+			continue
+		}
+		after := j + len(delim)
+		if delim[0] == '_' && after < len(s) && isWordChar(s[after]) {
+			continue
+		}
+		return after - start, s[open:j], true
+	}
+	return 0, "", false
+}
+
+// renderLink 解析 [文本](地址 "标题") 与 ![替代文本](地址)
+func renderLink(s string) (int, string, bool) {
+	image := s[0] == '!'
+	pos := 1
+	if image {
+		pos = 2
+	}
+
+	if len(s) > maxLinkLength {
+		s = s[:maxLinkLength]
+	}
+	depth := 1
+	j := pos
+	for ; j < len(s) && depth > 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+	}
+	if depth != 0 || j >= len(s) || s[j] != '(' {
+		return 0, "", false
+	}
+	text := s[pos : j-1]
+
+	k := j + 1
+	parens := 0
+	for ; k < len(s); k++ {
+		if s[k] == '\\' {
+			k++
+			continue
+		}
+		if s[k] == '(' {
+			parens++
+		} else if s[k] == ')' {
+			if parens == 0 {
+				break
+			}
+			parens--
+		} else if s[k] == '\n' {
+			return 0, "", false
+		}
+	}
+	if k >= len(s) {
+		return 0, "", false
+	}
+
+	dest := strings.TrimSpace(s[j+1 : k])
+	title := ""
+	if sp := strings.IndexAny(dest, " \t"); sp >= 0 {
+		t := strings.TrimSpace(dest[sp:])
+		if len(t) >= 2 && (t[0] == '"' && t[len(t)-1] == '"' || t[0] == '\'' && t[len(t)-1] == '\'') {
+			title = t[1 : len(t)-1]
+			dest = dest[:sp]
+		}
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+
+	attrs := ""
+	if title != "" {
+		attrs = ` title="` + html.EscapeString(title) + `"`
+	}
+	if image {
+		return k + 1, `<img src="` + html.EscapeString(dest) + `" alt="` + html.EscapeString(text) + `"` + attrs + ">", true
+	}
+	return k + 1, `<a href="` + html.EscapeString(dest) + `"` + attrs + ">" + renderInline(text) + "</a>", true
+}
+
+func renderCoord(dim, x, y, z string) string {
+	label := x + ", " + y + ", " + z
+	attrs := ` data-x="` + x + `" data-y="` + y + `" data-z="` + z + `"`
+	if dim != "" {
+		label = dimensionLabels[dim] + " " + label
+		attrs += ` data-dim="` + dim + `"`
+	}
+	return `<span class="mc-coord"` + attrs + ">📍 " + label + "</span>"
+}
+
+func renderItem(id string) string {
+	if !strings.Contains(id, ":") {
+		id = "minecraft:" + id
+	}
+	name := id[strings.LastIndex(id, ":")+1:]
+	name = strings.ReplaceAll(name[strings.LastIndex(name, "/")+1:], "_", " ")
+	return `<span class="mc-item" data-item="` + html.EscapeString(id) + `">` + html.EscapeString(name) + "</span>"
+}
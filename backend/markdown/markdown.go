@@ -0,0 +1,404 @@
+// Package markdown 把用户输入的 Markdown 渲染为经过白名单清洗的 HTML。
+//
+// 支持 CommonMark 的常用语法（标题、段落、引用、列表、代码块、链接、图片、强调、行内 HTML），
+// 以及表格、删除线、自动链接，和站内扩展：
+//
+//	||剧透文字||            行内剧透
+//	:::spoiler 标题 ... :::  折叠剧透块
+//	@[100, 64, -200]        坐标，可加维度前缀 @[nether: 100, 64, -200]
+//	[[diamond_sword]]       物品，可带命名空间 [[minecraft:diamond_sword]]
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version 渲染器版本，渲染规则变化时递增，启动时会重新渲染旧版本的内容
+const Version = 1
+
+// maxNestingDepth 引用、列表与剧透块的最大嵌套层数，更深的标记按普通文本处理。
+// 每层嵌套都会复制并重新解析内部的行，不加限制时深层嵌套的耗时随层数平方增长
+const maxNestingDepth = 16
+
+// Render 渲染 Markdown 并清洗输出
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	lines := strings.Split(src, "\n")
+	return Sanitize(renderBlocks(lines, false, 0))
+}
+
+var (
+	atxHeadingPattern   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ ]+(.*?))?(?:[ ]+#+)?[ ]*$`)
+	thematicPattern     = regexp.MustCompile(`^ {0,3}((?:\*[ ]*){3,}|(?:-[ ]*){3,}|(?:_[ ]*){3,})$`)
+	fencePattern        = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ ]*([^`\\s]*)")
+	listItemPattern     = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])( {1,4}|$)`)
+	htmlBlockPattern    = regexp.MustCompile(`(?i)^ {0,3}(?:<!--|</?(?:address|article|aside|blockquote|center|details|dialog|div|dl|dd|dt|fieldset|figcaption|figure|footer|form|h[1-6]|header|hr|iframe|li|main|nav|ol|p|pre|script|section|style|summary|table|tbody|td|tfoot|th|thead|tr|ul)(?:[\s/>]|$))`)
+	setextPattern       = regexp.MustCompile(`^ {0,3}(=+|-+)[ ]*$`)
+	tableDelimPattern   = regexp.MustCompile(`^ *\|? *:?-+:? *(?:\| *:?-+:? *)*\|? *$`)
+	spoilerOpenPattern  = regexp.MustCompile(`^ {0,3}:::[ ]*spoiler(?:[ ]+(.*))?$`)
+	spoilerClosePattern = regexp.MustCompile(`^ {0,3}:::[ ]*$`)
+)
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// startsBlock 判断一行是否会打断段落
+func startsBlock(line string) bool {
+	if atxHeadingPattern.MatchString(line) || thematicPattern.MatchString(line) ||
+		fencePattern.MatchString(line) || htmlBlockPattern.MatchString(line) ||
+		spoilerOpenPattern.MatchString(line) {
+		return true
+	}
+	trimmed := strings.TrimLeft(line, " ")
+	if indentOf(line) < 4 && strings.HasPrefix(trimmed, ">") {
+		return true
+	}
+	if m := listItemPattern.FindStringSubmatch(line); m != nil && strings.TrimSpace(line[len(m[0]):]) != "" {
+		// 有序列表只有从 1 开始才能打断段落
+		marker := m[2]
+		return !isOrderedMarker(marker) || strings.TrimRight(marker, ".)") == "1"
+	}
+	return false
+}
+
+func isOrderedMarker(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+// renderBlocks 逐行解析块级结构；tight 为真时段落不包 <p>（紧凑列表项），depth 为当前嵌套层数
+func renderBlocks(lines []string, tight bool, depth int) string {
+	var out strings.Builder
+	nested := depth < maxNestingDepth
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case fencePattern.MatchString(line):
+			i = renderFence(lines, i, &out)
+
+		case nested && spoilerOpenPattern.MatchString(line):
+			i = renderSpoiler(lines, i, depth, &out)
+
+		case atxHeadingPattern.MatchString(line):
+			m := atxHeadingPattern.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			out.WriteString("<h" + level + ">" + renderInline(strings.TrimSpace(m[2])) + "</h" + level + ">\n")
+			i++
+
+		case thematicPattern.MatchString(line):
+			out.WriteString("<hr>\n")
+			i++
+
+		case indentOf(line) >= 4:
+			i = renderIndentedCode(lines, i, &out)
+
+		case nested && strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			i = renderBlockquote(lines, i, depth, &out)
+
+		case nested && listItemPattern.MatchString(line):
+			i = renderList(lines, i, depth, &out)
+
+		case htmlBlockPattern.MatchString(line):
+			// 原样输出，交给清洗器过滤
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				out.WriteString(lines[i] + "\n")
+			}
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableDelimPattern.MatchString(lines[i+1]):
+			i = renderTable(lines, i, &out)
+
+		default:
+			i = renderParagraph(lines, i, tight, &out)
+		}
+	}
+	return out.String()
+}
+
+func renderFence(lines []string, i int, out *strings.Builder) int {
+	m := fencePattern.FindStringSubmatch(lines[i])
+	indent, fence, lang := len(m[1]), m[2], m[3]
+	var code strings.Builder
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if indentOf(lines[i]) < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" ") == "" {
+			i++
+			break
+		}
+		line := lines[i]
+		if n := indentOf(line); n > 0 {
+			line = line[min(n, indent):]
+		}
+		code.WriteString(line + "\n")
+	}
+	out.WriteString("<pre><code")
+	if lang != "" {
+		out.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	out.WriteString(">" + html.EscapeString(code.String()) + "</code></pre>\n")
+	return i
+}
+
+func renderIndentedCode(lines []string, i int, out *strings.Builder) int {
+	var code []string
+	for ; i < len(lines) && (indentOf(lines[i]) >= 4 || isBlank(lines[i])); i++ {
+		if isBlank(lines[i]) {
+			code = append(code, "")
+		} else {
+			code = append(code, lines[i][4:])
+		}
+	}
+	// 去掉尾部空行
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+	out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")+"\n") + "</code></pre>\n")
+	return i
+}
+
+func renderSpoiler(lines []string, i, depth int, out *strings.Builder) int {
+	title := strings.TrimSpace(spoilerOpenPattern.FindStringSubmatch(lines[i])[1])
+	if title == "" {
+		title = "剧透"
+	}
+	var inner []string
+	for i++; i < len(lines) && !spoilerClosePattern.MatchString(lines[i]); i++ {
+		inner = append(inner, lines[i])
+	}
+	if i < len(lines) {
+		i++
+	}
+	out.WriteString(`<details class="spoiler"><summary>` + renderInline(title) + "</summary>\n")
+	out.WriteString(renderBlocks(inner, false, depth+1))
+	out.WriteString("</details>\n")
+	return i
+}
+
+func renderBlockquote(lines []string, i, depth int, out *strings.Builder) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if strings.HasPrefix(trimmed, ">") && indentOf(lines[i]) < 4 {
+			trimmed = strings.TrimPrefix(trimmed, ">")
+			inner = append(inner, strings.TrimPrefix(trimmed, " "))
+			continue
+		}
+		// 段落的惰性续行
+		if !isBlank(lines[i]) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !startsBlock(lines[i]) {
+			inner = append(inner, lines[i])
+			continue
+		}
+		break
+	}
+	out.WriteString("<blockquote>\n" + renderBlocks(inner, false, depth+1) + "</blockquote>\n")
+	return i
+}
+
+func renderList(lines []string, i, depth int, out *strings.Builder) int {
+	first := listItemPattern.FindStringSubmatch(lines[i])
+	ordered := isOrderedMarker(first[2])
+	delim := first[2][len(first[2])-1:]
+	baseIndent := len(first[1])
+
+	// 同一列表的项：缩进相同且标记类型一致
+	sameList := func(m []string) bool {
+		return m != nil && len(m[1]) == baseIndent && isOrderedMarker(m[2]) == ordered && m[2][len(m[2])-1:] == delim
+	}
+
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		m := listItemPattern.FindStringSubmatch(lines[i])
+		if !sameList(m) {
+			break
+		}
+		contentIndent := len(m[0])
+		if strings.TrimSpace(lines[i][len(m[0]):]) == "" || len(m[3]) > 4 {
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		}
+		item := []string{strings.TrimLeft(lines[i][min(len(lines[i]), len(m[1])+len(m[2])):], " ")}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				// 空行后仍有缩进内容则属于当前项
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j < len(lines) && indentOf(lines[j]) >= contentIndent {
+					for ; i < j; i++ {
+						item = append(item, "")
+					}
+					loose = true
+					i--
+					continue
+				}
+				break
+			}
+			if indentOf(line) >= contentIndent {
+				item = append(item, line[contentIndent:])
+				continue
+			}
+			// 段落的惰性续行
+			if !startsBlock(line) && !listItemPattern.MatchString(line) && !isBlank(item[len(item)-1]) {
+				item = append(item, strings.TrimLeft(line, " "))
+				continue
+			}
+			break
+		}
+		items = append(items, item)
+
+		// 项与项之间的空行使列表变为松散列表
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j > i && j < len(lines) {
+			if sameList(listItemPattern.FindStringSubmatch(lines[j])) {
+				loose = true
+				i = j
+				continue
+			}
+		}
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	out.WriteString("<" + tag)
+	if ordered {
+		if start, _ := strconv.Atoi(strings.TrimRight(first[2], ".)")); start != 1 {
+			out.WriteString(` start="` + strconv.Itoa(start) + `"`)
+		}
+	}
+	out.WriteString(">\n")
+	for _, item := range items {
+		out.WriteString("<li>" + strings.TrimSuffix(renderBlocks(item, !loose, depth+1), "\n") + "</li>\n")
+	}
+	out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(line[i])
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func renderTable(lines []string, i int, out *strings.Builder) int {
+	header := splitTableRow(lines[i])
+	var aligns []string
+	for _, d := range splitTableRow(lines[i+1]) {
+		left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	if len(aligns) != len(header) {
+		return renderParagraph(lines, i, false, out)
+	}
+
+	cell := func(tag string, col int, text string) string {
+		open := "<" + tag
+		if aligns[col] != "" {
+			open += ` class="align-` + aligns[col] + `"`
+		}
+		return open + ">" + renderInline(text) + "</" + tag + ">"
+	}
+
+	out.WriteString("<table>\n<thead>\n<tr>")
+	for col, text := range header {
+		out.WriteString(cell("th", col, text))
+	}
+	out.WriteString("</tr>\n</thead>\n")
+
+	i += 2
+	if i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") {
+		out.WriteString("<tbody>\n")
+		for ; i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|"); i++ {
+			row := splitTableRow(lines[i])
+			out.WriteString("<tr>")
+			for col := range header {
+				text := ""
+				if col < len(row) {
+					text = row[col]
+				}
+				out.WriteString(cell("td", col, text))
+			}
+			out.WriteString("</tr>\n")
+		}
+		out.WriteString("</tbody>\n")
+	}
+	out.WriteString("</table>\n")
+	return i
+}
+
+func renderParagraph(lines []string, i int, tight bool, out *strings.Builder) int {
+	para := []string{strings.TrimLeft(lines[i], " ")}
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+		if m := setextPattern.FindStringSubmatch(line); m != nil {
+			level := "2"
+			if m[1][0] == '=' {
+				level = "1"
+			}
+			out.WriteString("<h" + level + ">" + renderInline(strings.Join(para, "\n")) + "</h" + level + ">\n")
+			return i + 1
+		}
+		if startsBlock(line) {
+			break
+		}
+		if i+1 < len(lines) && strings.Contains(line, "|") && tableDelimPattern.MatchString(lines[i+1]) {
+			break
+		}
+		para = append(para, strings.TrimLeft(line, " "))
+	}
+
+	text := renderInline(strings.TrimRight(strings.Join(para, "\n"), " "))
+	if tight {
+		out.WriteString(text + "\n")
+	} else {
+		out.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 深层嵌套的引用与列表超过 maxNestingDepth 后按普通文本处理，渲染耗时不能随层数平方增长
+func TestRenderDeepNesting(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		tag  string
+	}{
+		{"blockquote", strings.Repeat(">", 65000) + " x", "<blockquote>"},
+		{"blockquote with spaces", strings.Repeat("> ", 30000) + "x", "<blockquote>"},
+		{"list", strings.Repeat("- * ", 20000) + "x", "<li>"},
+		{"indented list", nestedList(200), "<li>"},
+		{"spoiler", strings.Repeat(":::spoiler\n", 5000) + "x", "<details"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			out := Render(tc.src)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("render took %v", elapsed)
+			}
+			if n := strings.Count(out, tc.tag); n > maxNestingDepth {
+				t.Fatalf("got %d nested %s, want at most %d", n, tc.tag, maxNestingDepth)
+			}
+		})
+	}
+}
+
+func TestRenderNestingBelowLimit(t *testing.T) {
+	out := Render(strings.Repeat(">", maxNestingDepth) + " x")
+	if n := strings.Count(out, "<blockquote>"); n != maxNestingDepth {
+		t.Fatalf("got %d blockquotes, want %d", n, maxNestingDepth)
+	}
+	out = Render("- a\n  - b\n    - c")
+	if n := strings.Count(out, "<ul>"); n != 3 {
+		t.Fatalf("got %d lists, want 3: %s", n, out)
+	}
+}
+
+func nestedList(depth int) string {
+	var b strings.Builder
+	for i := 0; i < depth; i++ {
+		b.WriteString(strings.Repeat("  ", i) + "- item\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,213 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// allowedTags 白名单标签及各自允许的属性
+var allowedTags = map[string]map[string]bool{
+	"p":  {},
+	"br": {},
+	"hr": {},
+	"h1": {},
+	"h2": {},
+	"h3": {},
+	"h4": {},
+	"h5": {},
+	"h6": {},
+
+	"strong": {},
+	"b":      {},
+	"em":     {},
+	"i":      {},
+	"u":      {},
+	"s":      {},
+	"del":    {},
+	"sub":    {},
+	"sup":    {},
+	"mark":   {},
+	"kbd":    {},
+	"small":  {},
+	"code":   {"class": true},
+	"pre":    {},
+
+	"blockquote": {},
+	"ul":         {},
+	"ol":         {"start": true},
+	"li":         {},
+	"a":          {"href": true, "title": true},
+	"img":        {"src": true, "alt": true, "title": true, "width": true, "height": true},
+
+	"table": {},
+	"thead": {},
+	"tbody": {},
+	"tr":    {},
+	"th":    {"class": true, "colspan": true, "rowspan": true},
+	"td":    {"class": true, "colspan": true, "rowspan": true},
+
+	"details": {"class": true},
+	"summary": {},
+	"div":     {"class": true},
+	"span":    {"class": true, "data-x": true, "data-y": true, "data-z": true, "data-dim": true, "data-item": true},
+}
+
+// droppedTags 连同内容一起丢弃的标签
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "textarea": true, "select": true,
+	"svg": true, "math": true, "title": true, "head": true, "frame": true, "frameset": true,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+var (
+	allowedClassPattern = regexp.MustCompile(`^(?:spoiler|mc-coord|mc-item|align-(?:left|center|right)|language-[A-Za-z0-9_+#-]{1,32})$`)
+	numberPattern       = regexp.MustCompile(`^-?\d{1,8}$`)
+	sizePattern         = regexp.MustCompile(`^\d{1,4}%?$`)
+	itemIDPattern       = regexp.MustCompile(`^(?:[a-z0-9_.-]+:)?[a-z0-9_./-]+$`)
+)
+
+// safeURL 只允许 http(s)、mailto 与站内相对地址
+func safeURL(raw string, allowMailto bool) bool {
+	u := strings.TrimSpace(raw)
+	if u == "" {
+		return false
+	}
+	lower := strings.ToLower(u)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return true
+	}
+	if allowMailto && strings.HasPrefix(lower, "mailto:") {
+		return true
+	}
+	if strings.HasPrefix(u, "//") {
+		return false
+	}
+	// 相对地址不能带协议
+	if i := strings.IndexAny(u, ":/?#"); i >= 0 && u[i] == ':' {
+		return false
+	}
+	return true
+}
+
+func allowedAttr(tag, key, val string) bool {
+	if !allowedTags[tag][key] {
+		return false
+	}
+	switch key {
+	case "href":
+		return safeURL(val, true)
+	case "src":
+		return safeURL(val, false)
+	case "class":
+		for _, cls := range strings.Fields(val) {
+			if !allowedClassPattern.MatchString(cls) {
+				return false
+			}
+		}
+		return true
+	case "data-x", "data-y", "data-z", "start":
+		return numberPattern.MatchString(val)
+	case "width", "height", "colspan", "rowspan":
+		return sizePattern.MatchString(val)
+	case "data-dim":
+		_, ok := dimensionLabels[val]
+		return ok
+	case "data-item":
+		return itemIDPattern.MatchString(val)
+	}
+	return true
+}
+
+// Sanitize 按白名单清洗 HTML：过滤标签与属性、校验链接协议并补齐未闭合的标签
+func Sanitize(src string) string {
+	var out strings.Builder
+	var stack []string
+	dropDepth := 0
+	z := nethtml.NewTokenizer(strings.NewReader(src))
+
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			// io.EOF 或解析错误都结束
+			break
+		}
+		tok := z.Token()
+		tag := strings.ToLower(tok.Data)
+
+		switch tt {
+		case nethtml.TextToken:
+			if dropDepth == 0 {
+				out.WriteString(html.EscapeString(tok.Data))
+			}
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if droppedTags[tag] {
+				if tt == nethtml.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			if dropDepth > 0 {
+				continue
+			}
+			attrs, ok := allowedTags[tag]
+			if !ok {
+				continue
+			}
+			out.WriteString("<" + tag)
+			for _, a := range tok.Attr {
+				key := strings.ToLower(a.Key)
+				if a.Namespace != "" || !attrs[key] || !allowedAttr(tag, key, a.Val) {
+					continue
+				}
+				out.WriteString(" " + key + `="` + html.EscapeString(a.Val) + `"`)
+			}
+			switch tag {
+			case "a":
+				out.WriteString(` rel="nofollow ugc noopener" target="_blank"`)
+			case "img":
+				out.WriteString(` loading="lazy"`)
+			}
+			out.WriteString(">")
+			if !voidTags[tag] {
+				if tt == nethtml.SelfClosingTagToken {
+					out.WriteString("</" + tag + ">")
+				} else {
+					stack = append(stack, tag)
+				}
+			}
+
+		case nethtml.EndTagToken:
+			if droppedTags[tag] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			if dropDepth > 0 {
+				continue
+			}
+			// 只关闭已打开的标签，中间未闭合的一并关闭
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] != tag {
+					continue
+				}
+				for j := len(stack) - 1; j >= i; j-- {
+					out.WriteString("</" + stack[j] + ">")
+				}
+				stack = stack[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		out.WriteString("</" + stack[i] + ">")
+	}
+	return out.String()
+}
@@ -0,0 +1,80 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// GIF 动画限制：DecodeAll 会为每一帧分配完整的调色板图像，帧数与总像素都需要在解码前限制
+const (
+	MaxGIFFrames = 500
+	MaxGIFPixels = 100_000_000
+)
+
+// checkGIF 在解码前遍历 GIF 数据块，统计帧数与各帧像素之和，超出限制时返回 ErrTooLarge
+func checkGIF(data []byte) error {
+	// 文件头 6 字节 + 逻辑屏幕描述符 7 字节
+	if len(data) < 13 || string(data[:3]) != "GIF" {
+		return ErrInvalid
+	}
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << ((flags & 0x07) + 1)
+	}
+
+	frames, pixels := 0, 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // 扩展块：标签 + 子块序列
+			if i+2 > len(data) {
+				return ErrInvalid
+			}
+			next, ok := skipSubBlocks(data, i+2)
+			if !ok {
+				return ErrInvalid
+			}
+			i = next
+		case 0x2C: // 图像描述符：位置、尺寸与标志，随后是可选的局部颜色表和 LZW 数据
+			if i+10 > len(data) {
+				return ErrInvalid
+			}
+			w := int(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int(binary.LittleEndian.Uint16(data[i+7:]))
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << ((flags & 0x07) + 1)
+			}
+			frames++
+			pixels += w * h
+			if frames > MaxGIFFrames || pixels > MaxGIFPixels {
+				return ErrTooLarge
+			}
+			// 跳过 LZW 最小码长
+			next, ok := skipSubBlocks(data, i+1)
+			if !ok {
+				return ErrInvalid
+			}
+			i = next
+		case 0x3B: // 结束标记
+			return nil
+		default:
+			return ErrInvalid
+		}
+	}
+	return nil
+}
+
+// skipSubBlocks 跳过以长度 0 结尾的子块序列，返回其后的位置
+func skipSubBlocks(data []byte, i int) (int, bool) {
+	for {
+		if i >= len(data) {
+			return 0, false
+		}
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, true
+		}
+		i += size
+	}
+}
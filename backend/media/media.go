@@ -0,0 +1,152 @@
+// Package media 负责上传文件的类型识别、图片元数据清理与缩略图生成
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+)
+
+// 图片限制，防止解码超大图片耗尽内存
+const (
+	MaxPixels     = 40_000_000
+	MaxDimension  = 16384
+	ThumbnailSize = 320
+	jpegQuality   = 90
+)
+
+var (
+	ErrUnsupported = errors.New("media: unsupported file type")
+	ErrInvalid     = errors.New("media: invalid image")
+	ErrTooLarge    = errors.New("media: image dimensions too large")
+)
+
+// allowedTypes 允许上传的 MIME 类型及保存时使用的扩展名，以嗅探结果为准
+var allowedTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// Sniff 按文件内容判断类型，返回 MIME 与扩展名；不在白名单中时返回 ErrUnsupported
+func Sniff(data []byte) (string, string, error) {
+	mime := http.DetectContentType(data)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	ext, ok := allowedTypes[mime]
+	if !ok {
+		return "", "", ErrUnsupported
+	}
+	return mime, ext, nil
+}
+
+// IsImage 判断 MIME 是否为图片
+func IsImage(mime string) bool {
+	return strings.HasPrefix(mime, "image/")
+}
+
+// Result 处理后的文件；Thumb 为空表示不需要缩略图（非图片、无法解码或原图已足够小）
+type Result struct {
+	Data      []byte
+	Width     int
+	Height    int
+	Thumb     []byte
+	ThumbType string
+	ThumbExt  string
+}
+
+// Process 清理图片中的 EXIF 等元数据并生成缩略图，其他类型原样返回。
+// JPEG 会按 EXIF 方向旋转后重新编码，PNG / GIF 重新编码丢弃附加块，WebP 直接移除元数据块
+func Process(data []byte, mime string) (*Result, error) {
+	switch mime {
+	case "image/jpeg", "image/png", "image/gif":
+	case "image/webp":
+		return processWebP(data)
+	default:
+		return &Result{Data: data}, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	var img image.Image
+	var buf bytes.Buffer
+	switch mime {
+	case "image/jpeg":
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err != nil {
+			return nil, ErrInvalid
+		}
+		img = orient(img, jpegOrientation(data))
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		if img, err = png.Decode(bytes.NewReader(data)); err != nil {
+			return nil, ErrInvalid
+		}
+		err = png.Encode(&buf, img)
+	case "image/gif":
+		if err := checkGIF(data); err != nil {
+			return nil, err
+		}
+		var g *gif.GIF
+		if g, err = gif.DecodeAll(bytes.NewReader(data)); err != nil || len(g.Image) == 0 {
+			return nil, ErrInvalid
+		}
+		img = firstFrame(g)
+		err = gif.EncodeAll(&buf, g)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	result := &Result{Data: buf.Bytes(), Width: b.Dx(), Height: b.Dy()}
+	if b.Dx() > ThumbnailSize || b.Dy() > ThumbnailSize {
+		thumb := Thumbnail(img, ThumbnailSize)
+		var tb bytes.Buffer
+		if mime == "image/jpeg" {
+			err = jpeg.Encode(&tb, thumb, &jpeg.Options{Quality: jpegQuality})
+			result.ThumbType, result.ThumbExt = "image/jpeg", ".jpg"
+		} else {
+			// 保留透明通道
+			err = png.Encode(&tb, thumb)
+			result.ThumbType, result.ThumbExt = "image/png", ".png"
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Thumb = tb.Bytes()
+	}
+	return result, nil
+}
+
+// firstFrame 把 GIF 第一帧绘制到完整画布上，作为缩略图来源
+func firstFrame(g *gif.GIF) image.Image {
+	frame := g.Image[0]
+	w, h := g.Config.Width, g.Config.Height
+	if w == 0 || h == 0 || frame.Bounds() == image.Rect(0, 0, w, h) {
+		return frame
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, w, h))
+	fb := frame.Bounds().Intersect(canvas.Bounds())
+	for y := fb.Min.Y; y < fb.Max.Y; y++ {
+		for x := fb.Min.X; x < fb.Max.X; x++ {
+			canvas.Set(x, y, frame.At(x, y))
+		}
+	}
+	return canvas
+}
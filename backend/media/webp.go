@@ -0,0 +1,68 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// processWebP 移除 WebP 中的 EXIF / XMP 块并读取尺寸；标准库无法解码 WebP，因此不生成缩略图
+func processWebP(data []byte) (*Result, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalid
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	width, height := 0, 0
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrInvalid
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size
+		if size < 0 || end > len(data) {
+			return nil, ErrInvalid
+		}
+		padded := end + size%2
+		if padded > len(data) {
+			padded = len(data)
+		}
+		payload := data[i+8 : end]
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			i = padded
+			continue
+		case "VP8X":
+			if len(payload) >= 10 {
+				width = int(uint32(payload[4])|uint32(payload[5])<<8|uint32(payload[6])<<16) + 1
+				height = int(uint32(payload[7])|uint32(payload[8])<<8|uint32(payload[9])<<16) + 1
+			}
+		case "VP8 ":
+			if width == 0 && len(payload) >= 10 {
+				width = int(binary.LittleEndian.Uint16(payload[6:]) & 0x3FFF)
+				height = int(binary.LittleEndian.Uint16(payload[8:]) & 0x3FFF)
+			}
+		case "VP8L":
+			if width == 0 && len(payload) >= 5 && payload[0] == 0x2F {
+				bits := binary.LittleEndian.Uint32(payload[1:])
+				width = int(bits&0x3FFF) + 1
+				height = int(bits>>14&0x3FFF) + 1
+			}
+		}
+
+		start := len(out)
+		out = append(out, data[i:padded]...)
+		if fourCC == "VP8X" && len(payload) > 0 {
+			// 清除扩展头中的 EXIF（0x08）与 XMP（0x04）标记
+			out[start+8] &^= 0x08 | 0x04
+		}
+		i = padded
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	if width > MaxDimension || height > MaxDimension || width*height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	return &Result{Data: out, Width: width, Height: height}, nil
+}
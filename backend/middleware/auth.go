@@ -1,44 +1,232 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
+	"time"
 
+	"hxzd-server/config"
+	"hxzd-server/models"
 	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware(secret string) gin.HandlerFunc {
+// 每个密钥最多每分钟写一次 last_used_at
+const apiKeyTouchInterval = time.Minute
+
+// AuthMiddleware 接受 Bearer JWT、X-API-Key，以及 Cookie 会话模式下的会话 Cookie
+func AuthMiddleware(cfg *config.Config, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "" {
+			if authenticateAPIKey(c, db) && !rejectBanned(c, db) {
+				c.Next()
+			}
+			return
+		}
+
+		var tokenStr string
 		auth := c.GetHeader("Authorization")
-		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+		if strings.HasPrefix(auth, "Bearer ") {
+			tokenStr = strings.TrimPrefix(auth, "Bearer ")
+		} else if cfg.CookieAuth {
+			if cookie, err := c.Cookie(utils.SessionCookieName); err == nil && cookie != "" {
+				// 浏览器会自动携带 Cookie，变更请求必须额外提交 CSRF 令牌
+				if !checkCSRF(c) {
+					c.JSON(http.StatusForbidden, gin.H{"error": "CSRF 校验失败，请刷新页面"})
+					c.Abort()
+					return
+				}
+				tokenStr = cookie
+			}
+		}
+		if tokenStr == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
 			c.Abort()
 			return
 		}
-		tokenStr := strings.TrimPrefix(auth, "Bearer ")
-		claims, err := utils.ParseToken(tokenStr, secret)
+		claims, err := utils.ParseToken(tokenStr, cfg.JWTSecret)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "登录已过期"})
 			c.Abort()
 			return
 		}
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
+		// 角色以数据库为准，令牌签发后的降级或删号立即生效
+		var user models.User
+		if err := db.Select("id", "username", "role").First(&user, claims.UserID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+			c.Abort()
+			return
+		}
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		if rejectBanned(c, db) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkCSRF 双提交校验：X-CSRF-Token 请求头必须与 CSRF Cookie 一致，安全方法不校验
+func checkCSRF(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, err := c.Cookie(utils.CSRFCookieName)
+	if err != nil || cookie == "" {
+		return false
+	}
+	header := c.GetHeader(utils.CSRFHeaderName)
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// rejectBanned 已被封禁的账号即使持有有效凭证也拒绝访问
+func rejectBanned(c *gin.Context, db *gorm.DB) bool {
+	userID := c.GetUint("user_id")
+	s := utils.ActiveSanction(db, userID, utils.SanctionBan)
+	if s == nil {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "账号已被封禁", "sanction": utils.SanctionInfo(s)})
+	c.Abort()
+	return true
+}
+
+// authenticateAPIKey 校验 X-API-Key 并以密钥所有者身份写入上下文，失败时已写入响应
+func authenticateAPIKey(c *gin.Context, db *gorm.DB) bool {
+	var key models.APIKey
+	hash := utils.HashAPIKey(c.GetHeader("X-API-Key"))
+	if err := db.Preload("Owner").Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API 密钥无效"})
+		c.Abort()
+		return false
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API 密钥已过期"})
+		c.Abort()
+		return false
+	}
+	if key.Owner.ID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API 密钥所有者不存在"})
+		c.Abort()
+		return false
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		db.Model(&key).UpdateColumn("last_used_at", now)
+	}
+
+	c.Set("user_id", key.Owner.ID)
+	c.Set("username", key.Owner.Username)
+	c.Set("role", key.Owner.Role)
+	c.Set("api_key_id", key.ID)
+	c.Set("api_scopes", key.Scopes)
+	return true
+}
+
+// hasScope 非 API 密钥请求不受作用域限制
+func hasScope(c *gin.Context, scope string) bool {
+	v, isKey := c.Get("api_scopes")
+	if !isKey {
+		return true
+	}
+	scopes, _ := v.([]string)
+	for _, s := range scopes {
+		if s == scope || s == utils.PermAll {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope 限制 API 密钥只能访问授予了对应作用域的路由组
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasScope(c, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API 密钥缺少作用域: " + scope})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission 要求当前角色拥有任一给定权限；API 密钥还需授予对应作用域
+func RequirePermission(store *utils.PermissionStore, perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		roleName, _ := role.(string)
+		if !store.Has(roleName, perms...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+			c.Abort()
+			return
+		}
+		scoped := false
+		for _, p := range perms {
+			if hasScope(c, p) {
+				scoped = true
+				break
+			}
+		}
+		if !scoped {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API 密钥缺少作用域"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
-func AdminOnly() gin.HandlerFunc {
+// PluginAuth 校验游戏内插件回调：共享密钥或带 plugin 作用域的 API 密钥
+func PluginAuth(secret string, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+		if c.GetHeader("X-API-Key") != "" {
+			if !authenticateAPIKey(c, db) || rejectBanned(c, db) {
+				return
+			}
+			if !hasScope(c, utils.ScopePlugin) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API 密钥缺少作用域: " + utils.ScopePlugin})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		got := c.GetHeader("X-Plugin-Secret")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "插件密钥无效"})
 			c.Abort()
 			return
 		}
 		c.Next()
 	}
 }
+
+// OptionalAuth 公开接口上识别已登录用户以按权限展示内容，凭证缺失或无效时按游客处理
+func OptionalAuth(cfg *config.Config, db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tokenStr string
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			tokenStr = strings.TrimPrefix(auth, "Bearer ")
+		} else if cfg.CookieAuth {
+			tokenStr, _ = c.Cookie(utils.SessionCookieName)
+		}
+		if tokenStr != "" {
+			claims, err := utils.ParseToken(tokenStr, cfg.JWTSecret)
+			var user models.User
+			if err == nil && db.Select("id", "username", "role").First(&user, claims.UserID).Error == nil &&
+				utils.ActiveSanction(db, user.ID, utils.SanctionBan) == nil {
+				c.Set("user_id", user.ID)
+				c.Set("username", user.Username)
+				c.Set("role", user.Role)
+			}
+		}
+		c.Next()
+	}
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	ID          uint      `gorm:"primarykey" json:"id"`
@@ -12,41 +16,271 @@ type User struct {
 	Role        string    `gorm:"size:20;default:user" json:"role"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 默认管理员等弱密码账号，修改密码前一直提示
+	MustChangePassword bool `gorm:"default:false" json:"-"`
+
+	// 游戏内验证通过后写入，未绑定时为 NULL 以免触发唯一索引冲突
+	MinecraftUUID     *string    `gorm:"uniqueIndex;size:36" json:"minecraft_uuid"`
+	MinecraftLinkedAt *time.Time `json:"minecraft_linked_at"`
+
+	// 由角色解析得到，不落库
+	Permissions []string `gorm:"-" json:"permissions,omitempty"`
+	// 当前生效的封禁/禁言，仅 /auth/me 返回
+	Sanctions []UserSanction `gorm:"-" json:"sanctions,omitempty"`
+	// 未读通知数，仅 /auth/me 返回
+	UnreadNotifications *int64 `gorm:"-" json:"unread_notifications,omitempty"`
+	// 是否需要修改密码，仅登录响应与 /auth/me 返回
+	PasswordChangeRequired *bool `gorm:"-" json:"must_change_password,omitempty"`
+}
+
+// Role 角色及其权限集合，User.Role 存储角色名
+type Role struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:20;not null" json:"name"`
+	Label       string    `gorm:"size:64" json:"label"`
+	Description string    `gorm:"size:255" json:"description"`
+	Permissions []string  `gorm:"serializer:json;type:text" json:"permissions"`
+	IsSystem    bool      `gorm:"default:false" json:"is_system"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UserIdentity 绑定到站内用户的第三方登录账号
+type UserIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Provider  string    `gorm:"size:64;uniqueIndex:idx_identity_subject;not null" json:"provider"`
+	Subject   string    `gorm:"size:255;uniqueIndex:idx_identity_subject;not null" json:"subject"`
+	Username  string    `gorm:"size:255" json:"username"`
+	Email     string    `gorm:"size:255" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MinecraftLinkCode 游戏内绑定验证码
+type MinecraftLinkCode struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Code      string    `gorm:"uniqueIndex;size:16;not null" json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Announcement struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	Title         string    `gorm:"size:255;not null" json:"title"`
+	Content       string    `gorm:"type:text" json:"content"`
+	ContentHTML   string    `gorm:"type:longtext" json:"content_html"`
+	RenderVersion int       `gorm:"default:0" json:"-"`
+	AuthorID      uint      `json:"author_id"`
+	Author        User      `gorm:"foreignKey:AuthorID" json:"author"`
+	IsPinned      bool      `gorm:"default:false" json:"is_pinned"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ForumPost 论坛帖子；LastActivityAt 为发帖或最近一次评论的时间，
+// idx_post_latest / idx_post_activity 对应列表的游标分页排序
+type ForumPost struct {
+	ID             uint            `gorm:"primarykey" json:"id"`
+	Title          string          `gorm:"size:255;not null" json:"title"`
+	Content        string          `gorm:"type:text" json:"content"`
+	ContentHTML    string          `gorm:"type:longtext" json:"content_html"`
+	RenderVersion  int             `gorm:"default:0" json:"-"`
+	AuthorID       uint            `json:"author_id"`
+	Author         User            `gorm:"foreignKey:AuthorID" json:"author"`
+	Category       string          `gorm:"size:64" json:"category"`
+	IsPinned       bool            `gorm:"default:false;index:idx_post_latest,priority:1;index:idx_post_activity,priority:1" json:"is_pinned"`
+	IsHidden       bool            `gorm:"default:false;index" json:"is_hidden"`
+	EditCount      int             `gorm:"default:0" json:"edit_count"`
+	EditedAt       *time.Time      `json:"edited_at"`
+	DeletedAt      gorm.DeletedAt  `gorm:"index" json:"deleted_at"`
+	DeletedByID    *uint           `json:"deleted_by_id,omitempty"`
+	DeletedBy      *User           `gorm:"foreignKey:DeletedByID" json:"deleted_by,omitempty"`
+	DeleteReason   string          `gorm:"size:255" json:"delete_reason,omitempty"`
+	ViewCount      int             `gorm:"default:0" json:"view_count"`
+	CommentCount   int             `gorm:"default:0" json:"comment_count"`
+	ReactionCount  int             `gorm:"default:0" json:"reaction_count"`
+	Reactions      []ReactionCount `gorm:"-" json:"reactions"`
+	Watching       bool            `gorm:"-" json:"watching"`
+	Poll           *ForumPoll      `gorm:"foreignKey:PostID" json:"poll,omitempty"`
+	Tags           []ForumTag      `gorm:"many2many:forum_post_tags;joinForeignKey:PostID;joinReferences:TagID" json:"tags"`
+	Comments       []ForumComment  `gorm:"foreignKey:PostID" json:"comments,omitempty"`
+	LastActivityAt time.Time       `gorm:"index:idx_post_activity,priority:2" json:"last_activity_at"`
+	CreatedAt      time.Time       `gorm:"index:idx_post_latest,priority:2" json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ForumPostRevision 帖子的历史版本，第 1 版为原始内容，之后每次编辑追加一版
+type ForumPostRevision struct {
 	ID        uint      `gorm:"primarykey" json:"id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_revision_version,priority:1" json:"post_id"`
+	Version   int       `gorm:"not null;uniqueIndex:idx_revision_version,priority:2" json:"version"`
 	Title     string    `gorm:"size:255;not null" json:"title"`
 	Content   string    `gorm:"type:text" json:"content"`
-	AuthorID  uint      `json:"author_id"`
-	Author    User      `gorm:"foreignKey:AuthorID" json:"author"`
-	IsPinned  bool      `gorm:"default:false" json:"is_pinned"`
+	Reason    string    `gorm:"size:255" json:"reason"`
+	EditorID  uint      `json:"editor_id"`
+	Editor    User      `gorm:"foreignKey:EditorID" json:"editor"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type ForumPost struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	Title     string         `gorm:"size:255;not null" json:"title"`
-	Content   string         `gorm:"type:text" json:"content"`
-	AuthorID  uint           `json:"author_id"`
-	Author    User           `gorm:"foreignKey:AuthorID" json:"author"`
-	Category  string         `gorm:"size:64" json:"category"`
-	IsPinned  bool           `gorm:"default:false" json:"is_pinned"`
-	ViewCount int            `gorm:"default:0" json:"view_count"`
-	Comments  []ForumComment `gorm:"foreignKey:PostID" json:"comments,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+// ForumCategory 论坛分类，ReadPermission 为空表示所有人可见，PostPermission 为空表示登录用户均可发帖
+type ForumCategory struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	Slug           string     `gorm:"uniqueIndex;size:64;not null" json:"slug"`
+	Name           string     `gorm:"size:64;not null" json:"name"`
+	Description    string     `gorm:"size:255" json:"description"`
+	Icon           string     `gorm:"size:64" json:"icon"`
+	SortOrder      int        `gorm:"default:0" json:"sort_order"`
+	ReadPermission string     `gorm:"size:64" json:"read_permission"`
+	PostPermission string     `gorm:"size:64" json:"post_permission"`
+	PostCount      int        `gorm:"default:0" json:"post_count"`
+	LastActivityAt *time.Time `json:"last_activity_at"`
+	CanPost        bool       `gorm:"-" json:"can_post"`
+	Watching       bool       `gorm:"-" json:"watching"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
+// ForumComment 论坛评论，ParentID 指向被回复的评论，QuoteID 指向被引用的评论；
+// 删除只标记 IsDeleted 并保留内容以便恢复，有回复的已删除评论显示为占位
 type ForumComment struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	PostID        uint            `gorm:"index" json:"post_id"`
+	ParentID      *uint           `gorm:"index" json:"parent_id"`
+	Depth         int             `gorm:"default:0" json:"depth"`
+	ReplyToUserID *uint           `json:"reply_to_user_id"`
+	ReplyToUser   *User           `gorm:"foreignKey:ReplyToUserID" json:"reply_to_user,omitempty"`
+	QuoteID       *uint           `json:"quote_id"`
+	Quote         *ForumComment   `gorm:"foreignKey:QuoteID" json:"quote,omitempty"`
+	AuthorID      uint            `json:"author_id"`
+	Author        User            `gorm:"foreignKey:AuthorID" json:"author"`
+	Content       string          `gorm:"type:text" json:"content"`
+	ContentHTML   string          `gorm:"type:longtext" json:"content_html"`
+	RenderVersion int             `gorm:"default:0" json:"-"`
+	IsDeleted     bool            `gorm:"default:false" json:"is_deleted"`
+	DeletedAt     *time.Time      `gorm:"index" json:"deleted_at,omitempty"`
+	DeletedByID   *uint           `json:"deleted_by_id,omitempty"`
+	DeletedBy     *User           `gorm:"foreignKey:DeletedByID" json:"deleted_by,omitempty"`
+	DeleteReason  string          `gorm:"size:255" json:"delete_reason,omitempty"`
+	IsHidden      bool            `gorm:"default:false" json:"is_hidden"`
+	Reactions     []ReactionCount `gorm:"-" json:"reactions,omitempty"`
+	Replies       []ForumComment  `gorm:"-" json:"replies,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// ForumTag 帖子标签；Curated 为版主维护的推荐标签，其余由用户发帖时自由添加
+type ForumTag struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:32;uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Curated     bool      `gorm:"default:false;index" json:"curated"`
+	PostCount   int       `gorm:"default:0" json:"post_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ForumPostTag 帖子与标签的关联
+type ForumPostTag struct {
+	PostID uint `gorm:"primaryKey;autoIncrement:false" json:"post_id"`
+	TagID  uint `gorm:"primaryKey;autoIncrement:false;index" json:"tag_id"`
+}
+
+// ForumPoll 帖子附带的投票，每个用户只能投一次（多选时一次可选多项）
+type ForumPoll struct {
+	ID          uint              `gorm:"primarykey" json:"id"`
+	PostID      uint              `gorm:"uniqueIndex;not null" json:"post_id"`
+	Multiple    bool              `gorm:"default:false" json:"multiple"`
+	Anonymous   bool              `gorm:"default:false" json:"anonymous"`
+	EndsAt      *time.Time        `json:"ends_at"`
+	VoterCount  int               `gorm:"default:0" json:"voter_count"`
+	Options     []ForumPollOption `gorm:"foreignKey:PollID" json:"options"`
+	Closed      bool              `gorm:"-" json:"closed"`
+	ShowResults bool              `gorm:"-" json:"show_results"`
+	MyVotes     []uint            `gorm:"-" json:"my_votes"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ForumPollOption 投票选项，结果未公开时 VoteCount 不返回真实值
+type ForumPollOption struct {
+	ID        uint     `gorm:"primarykey" json:"id"`
+	PollID    uint     `gorm:"index;not null" json:"poll_id"`
+	Text      string   `gorm:"size:100;not null" json:"text"`
+	SortOrder int      `gorm:"default:0" json:"sort_order"`
+	VoteCount int      `gorm:"default:0" json:"vote_count"`
+	Voters    []string `gorm:"-" json:"voters,omitempty"`
+}
+
+// ForumPollVote 用户选择的一个选项
+type ForumPollVote struct {
 	ID        uint      `gorm:"primarykey" json:"id"`
-	PostID    uint      `json:"post_id"`
-	AuthorID  uint      `json:"author_id"`
-	Author    User      `gorm:"foreignKey:AuthorID" json:"author"`
-	Content   string    `gorm:"type:text" json:"content"`
+	PollID    uint      `gorm:"not null;uniqueIndex:idx_poll_vote,priority:1" json:"poll_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_poll_vote,priority:2" json:"user_id"`
+	OptionID  uint      `gorm:"not null;uniqueIndex:idx_poll_vote,priority:3;index" json:"option_id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ForumReaction 帖子或评论上的表情回应，同一用户对同一目标的同一表情只能有一条
+type ForumReaction struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	TargetType string    `gorm:"size:16;not null;uniqueIndex:idx_reaction_unique,priority:1" json:"target_type"`
+	TargetID   uint      `gorm:"not null;uniqueIndex:idx_reaction_unique,priority:2" json:"target_id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_reaction_unique,priority:3" json:"user_id"`
+	Emoji      string    `gorm:"size:32;not null;uniqueIndex:idx_reaction_unique,priority:4" json:"emoji"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReactionCount 某个表情的聚合数量，Reacted 表示当前用户是否已回应
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// Notification 站内通知，ReadAt 为空表示未读
+type Notification struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index:idx_notification_user,priority:1;not null" json:"user_id"`
+	Type       string     `gorm:"size:32;not null" json:"type"`
+	ActorID    *uint      `json:"actor_id"`
+	ActorName  string     `gorm:"size:64" json:"actor_name"`
+	Title      string     `gorm:"size:255" json:"title"`
+	Body       string     `gorm:"size:512" json:"body"`
+	Link       string     `gorm:"size:255" json:"link"`
+	TargetType string     `gorm:"size:32" json:"target_type"`
+	TargetID   uint       `json:"target_id"`
+	ReadAt     *time.Time `gorm:"index:idx_notification_user,priority:2" json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NotificationPreference 用户关闭的通知类型，没有记录时默认开启
+type NotificationPreference struct {
+	ID      uint   `gorm:"primarykey" json:"id"`
+	UserID  uint   `gorm:"uniqueIndex:idx_notification_pref,priority:1;not null" json:"user_id"`
+	Type    string `gorm:"uniqueIndex:idx_notification_pref,priority:2;size:32;not null" json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Subscription 用户关注的帖子或分类；Watching 为 false 表示用户主动取消过，之后不再自动关注
+type Subscription struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_subscription_unique,priority:1" json:"user_id"`
+	TargetType string    `gorm:"size:16;not null;uniqueIndex:idx_subscription_unique,priority:2;index:idx_subscription_target,priority:1" json:"target_type"`
+	TargetID   uint      `gorm:"not null;uniqueIndex:idx_subscription_unique,priority:3;index:idx_subscription_target,priority:2" json:"target_id"`
+	Watching   bool      `gorm:"default:true" json:"watching"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NotificationDigest 通知邮件的发送频率与进度，没有记录表示不发送邮件
+type NotificationDigest struct {
+	ID                 uint       `gorm:"primarykey" json:"id"`
+	UserID             uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	Frequency          string     `gorm:"size:16;index;not null" json:"frequency"`
+	LastNotificationID uint       `json:"-"`
+	LastSentAt         *time.Time `json:"last_sent_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 type SiteSetting struct {
@@ -56,12 +290,21 @@ type SiteSetting struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// SchemaMigration 已执行过的一次性数据迁移
+type SchemaMigration struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"uniqueIndex;size:128;not null"`
+	CreatedAt time.Time
+}
+
 type Page struct {
-	ID        uint      `gorm:"primarykey" json:"id"`
-	Slug      string    `gorm:"uniqueIndex;size:64;not null" json:"slug"`
-	Title     string    `gorm:"size:255" json:"title"`
-	Content   string    `gorm:"type:longtext" json:"content"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint      `gorm:"primarykey" json:"id"`
+	Slug          string    `gorm:"uniqueIndex;size:64;not null" json:"slug"`
+	Title         string    `gorm:"size:255" json:"title"`
+	Content       string    `gorm:"type:longtext" json:"content"`
+	ContentHTML   string    `gorm:"type:longtext" json:"content_html"`
+	RenderVersion int       `gorm:"default:0" json:"-"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 type ServerStatusConfig struct {
@@ -94,3 +337,101 @@ type WorldMap struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// APIKey 供机器人与插件调用的密钥，仅保存 SHA-256 摘要
+type APIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	Name       string     `gorm:"size:128;not null" json:"name"`
+	Prefix     string     `gorm:"size:16" json:"prefix"`
+	KeyHash    string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Scopes     []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	OwnerID    uint       `gorm:"index" json:"owner_id"`
+	Owner      User       `gorm:"foreignKey:OwnerID" json:"owner"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// UserSanction 站点封禁（禁止登录）与禁言（只读）
+type UserSanction struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID" json:"user"`
+	Type       string     `gorm:"size:16;index;not null" json:"type"`
+	Reason     string     `gorm:"size:512" json:"reason"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	IssuedByID uint       `json:"issued_by_id"`
+	IssuedBy   User       `gorm:"foreignKey:IssuedByID" json:"issued_by"`
+	LiftedAt   *time.Time `json:"lifted_at"`
+	LiftedByID *uint      `json:"lifted_by_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ContentReport 用户对帖子、评论或用户的举报，同一用户对同一目标只能有一条待处理举报；
+// 目标被自动隐藏时，当时待处理的举报标记 AutoHidden，驳回时据此撤销隐藏
+type ContentReport struct {
+	ID          uint          `gorm:"primarykey" json:"id"`
+	TargetType  string        `gorm:"size:16;not null;index:idx_report_target" json:"target_type"`
+	TargetID    uint          `gorm:"not null;index:idx_report_target" json:"target_id"`
+	ReporterID  uint          `gorm:"index;not null" json:"reporter_id"`
+	Reporter    User          `gorm:"foreignKey:ReporterID" json:"reporter"`
+	Reason      string        `gorm:"size:32;not null" json:"reason"`
+	Detail      string        `gorm:"size:512" json:"detail"`
+	Status      string        `gorm:"size:16;index;default:pending" json:"status"`
+	Action      string        `gorm:"size:16" json:"action"`
+	Note        string        `gorm:"size:512" json:"note"`
+	HandledByID *uint         `json:"handled_by_id"`
+	HandledBy   *User         `gorm:"foreignKey:HandledByID" json:"handled_by,omitempty"`
+	HandledAt   *time.Time    `json:"handled_at"`
+	AutoHidden  bool          `gorm:"default:false" json:"auto_hidden"`
+	Target      *ReportTarget `gorm:"-" json:"target,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// ReportTarget 审核队列中展示的被举报内容摘要
+type ReportTarget struct {
+	Exists     bool   `json:"exists"`
+	Title      string `json:"title"`
+	Excerpt    string `json:"excerpt"`
+	AuthorID   uint   `json:"author_id"`
+	AuthorName string `json:"author_name"`
+	IsHidden   bool   `json:"is_hidden"`
+	Link       string `json:"link"`
+	Reports    int64  `json:"reports"`
+}
+
+// Attachment 上传的图片或文件；TargetType 为空表示尚未被任何内容引用，超过保留期后会被回收
+type Attachment struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	UploaderID uint      `gorm:"index;not null" json:"uploader_id"`
+	Uploader   User      `gorm:"foreignKey:UploaderID" json:"uploader"`
+	StorageKey string    `gorm:"size:255;uniqueIndex;not null" json:"storage_key"`
+	ThumbKey   string    `gorm:"size:255;index" json:"thumb_key"`
+	ThumbType  string    `gorm:"size:100" json:"-"`
+	Filename   string    `gorm:"size:255" json:"filename"`
+	MimeType   string    `gorm:"size:100" json:"mime_type"`
+	Size       int64     `json:"size"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	TargetType string    `gorm:"size:32;index:idx_attachment_target,priority:1" json:"target_type"`
+	TargetID   uint      `gorm:"index:idx_attachment_target,priority:2" json:"target_id"`
+	URL        string    `gorm:"-" json:"url"`
+	ThumbURL   string    `gorm:"-" json:"thumb_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `gorm:"index" json:"updated_at"`
+}
+
+// AuditLog 管理操作审计记录
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ActorID    uint      `gorm:"index" json:"actor_id"`
+	ActorName  string    `gorm:"size:64" json:"actor_name"`
+	APIKeyID   *uint     `json:"api_key_id"`
+	Action     string    `gorm:"size:64;index" json:"action"`
+	TargetType string    `gorm:"size:64;index:idx_audit_target" json:"target_type"`
+	TargetID   string    `gorm:"size:128;index:idx_audit_target" json:"target_id"`
+	Changes    string    `gorm:"type:text" json:"changes"`
+	IP         string    `gorm:"size:64" json:"ip"`
+	UserAgent  string    `gorm:"size:512" json:"user_agent"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"hxzd-server/mailer"
+	"hxzd-server/models"
+	"hxzd-server/utils"
+
+	"gorm.io/gorm"
+)
+
+// 通知邮件的发送频率
+const (
+	DigestOff     = "off"
+	DigestInstant = "instant"
+	DigestDaily   = "daily"
+	DigestWeekly  = "weekly"
+)
+
+// DigestFrequencies 可选的邮件频率
+var DigestFrequencies = []struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}{
+	{DigestOff, "不发送邮件"},
+	{DigestInstant, "有新通知时立即发送"},
+	{DigestDaily, "每日摘要"},
+	{DigestWeekly, "每周摘要"},
+}
+
+// IsValidDigest 判断邮件频率是否存在
+func IsValidDigest(f string) bool {
+	for _, item := range DigestFrequencies {
+		if item.Key == f {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	digestInterval = time.Minute
+	digestMaxItems = 50
+	digestBatch    = 200
+)
+
+// Digester 后台任务：按用户选择的频率把未读通知汇总成邮件发送
+type Digester struct {
+	DB      *gorm.DB
+	Mailer  mailer.Mailer
+	SiteURL string
+}
+
+// NewDigester 创建邮件任务，mailer 为 nil（未配置邮件）时不启动
+func NewDigester(db *gorm.DB, m mailer.Mailer, siteURL string) *Digester {
+	d := &Digester{DB: db, Mailer: m, SiteURL: siteURL}
+	if m != nil {
+		go d.loop()
+	}
+	return d
+}
+
+// Enabled 是否配置了邮件发送
+func (d *Digester) Enabled() bool {
+	return d.Mailer != nil
+}
+
+func (d *Digester) loop() {
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		d.Run(time.Now())
+	}
+}
+
+// Run 处理所有到期的用户：即时模式每次都检查，每日 / 每周模式距上次发送满一个周期后检查
+func (d *Digester) Run(now time.Time) {
+	var lastID uint
+	for {
+		var batch []models.NotificationDigest
+		d.DB.Where("id > ?", lastID).
+			Where("frequency = ? OR (frequency = ? AND (last_sent_at IS NULL OR last_sent_at <= ?)) OR (frequency = ? AND (last_sent_at IS NULL OR last_sent_at <= ?))",
+				DigestInstant, DigestDaily, now.Add(-24*time.Hour), DigestWeekly, now.Add(-7*24*time.Hour)).
+			Order("id ASC").Limit(digestBatch).Find(&batch)
+		for i := range batch {
+			d.deliver(&batch[i], now)
+		}
+		if len(batch) < digestBatch {
+			return
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// deliver 汇总上次发送之后的未读通知；已读的通知直接跳过，发送失败时保留进度下次重试
+func (d *Digester) deliver(digest *models.NotificationDigest, now time.Time) {
+	var latestID uint
+	d.DB.Model(&models.Notification{}).Where("user_id = ?", digest.UserID).
+		Select("COALESCE(MAX(id), 0)").Scan(&latestID)
+	if latestID <= digest.LastNotificationID {
+		if digest.Frequency != DigestInstant {
+			d.DB.Model(digest).UpdateColumn("last_sent_at", now)
+		}
+		return
+	}
+
+	var items []models.Notification
+	d.DB.Where("user_id = ? AND id > ? AND id <= ? AND read_at IS NULL", digest.UserID, digest.LastNotificationID, latestID).
+		Order("id ASC").Limit(digestMaxItems + 1).Find(&items)
+	var user models.User
+	if len(items) > 0 && d.DB.First(&user, digest.UserID).Error == nil && user.Email != "" {
+		more := len(items) > digestMaxItems
+		if more {
+			items = items[:digestMaxItems]
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := d.Mailer.Send(ctx, d.compose(&user, digest.Frequency, items, more))
+		cancel()
+		if err != nil {
+			log.Printf("[notify] send digest to user %d failed: %v", user.ID, err)
+			return
+		}
+	}
+	d.DB.Model(digest).UpdateColumns(map[string]interface{}{
+		"last_notification_id": latestID,
+		"last_sent_at":         now,
+	})
+}
+
+func (d *Digester) compose(user *models.User, frequency string, items []models.Notification, more bool) mailer.Message {
+	site := utils.GetSetting(d.DB, "site_title", "HXZD")
+
+	var subject string
+	switch {
+	case frequency == DigestDaily:
+		subject = fmt.Sprintf("[%s] 每日通知摘要（%d 条）", site, len(items))
+	case frequency == DigestWeekly:
+		subject = fmt.Sprintf("[%s] 每周通知摘要（%d 条）", site, len(items))
+	case len(items) == 1:
+		subject = fmt.Sprintf("[%s] %s", site, items[0].Title)
+	default:
+		subject = fmt.Sprintf("[%s] 你有 %d 条新通知", site, len(items))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s，你好：\n\n以下是你在 %s 的新通知：\n\n", user.Username, site)
+	for _, n := range items {
+		b.WriteString("· " + n.Title + "\n")
+		if n.Body != "" {
+			b.WriteString("  " + n.Body + "\n")
+		}
+		if n.Link != "" {
+			b.WriteString("  " + d.SiteURL + n.Link + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if more {
+		b.WriteString("还有更多未读通知，请前往站点查看。\n\n")
+	}
+	fmt.Fprintf(&b, "—\n在 %s/forum 的通知面板中可以修改邮件频率或关闭邮件。\n", d.SiteURL)
+
+	return mailer.Message{To: user.Email, Subject: subject, Text: b.String()}
+}
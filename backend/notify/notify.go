@@ -0,0 +1,187 @@
+// Package notify 负责站内通知的写入、按用户偏好过滤以及实时推送
+package notify
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"hxzd-server/models"
+
+	"gorm.io/gorm"
+)
+
+// 通知类型
+const (
+	TypeReply        = "reply"
+	TypeMention      = "mention"
+	TypeAnnouncement = "announcement"
+	TypeModeration   = "moderation"
+	TypeSubscription = "subscription"
+)
+
+// Types 可在偏好设置中开关的通知类型
+var Types = []struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}{
+	{TypeReply, "帖子与评论被回复"},
+	{TypeMention, "被 @ 提及"},
+	{TypeAnnouncement, "新公告"},
+	{TypeModeration, "版务处理结果"},
+	{TypeSubscription, "关注的帖子与分类有新动态"},
+}
+
+// IsValidType 判断通知类型是否存在
+func IsValidType(t string) bool {
+	for _, item := range Types {
+		if item.Key == t {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	excerptLength   = 100
+	maxMentions     = 10
+	subscriberQueue = 16
+	broadcastBatch  = 500
+)
+
+// mentionPattern 匹配 @用户名，坐标语法 @[x, y, z] 不会命中
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_])@([A-Za-z0-9_]{2,32})`)
+
+// MentionedUsernames 提取内容中 @ 到的用户名（去重，最多 maxMentions 个）
+func MentionedUsernames(content string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := m[1]
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+		if len(names) == maxMentions {
+			break
+		}
+	}
+	return names
+}
+
+// Excerpt 截取通知正文摘要
+func Excerpt(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= excerptLength {
+		return s
+	}
+	return string([]rune(s)[:excerptLength]) + "…"
+}
+
+// Hub 按用户维护实时订阅，推送失败（队列已满）时直接丢弃，客户端可通过列表接口补齐
+type Hub struct {
+	mu   sync.Mutex
+	subs map[uint]map[chan models.Notification]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[uint]map[chan models.Notification]struct{}{}}
+}
+
+// Subscribe 订阅某个用户的通知，返回的取消函数必须调用
+func (h *Hub) Subscribe(userID uint) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, subscriberQueue)
+	h.mu.Lock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[chan models.Notification]struct{}{}
+	}
+	h.subs[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[userID], ch)
+		if len(h.subs[userID]) == 0 {
+			delete(h.subs, userID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *Hub) publish(n models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[n.UserID] {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+}
+
+// Notifier 写入通知并推送给在线用户
+type Notifier struct {
+	DB  *gorm.DB
+	Hub *Hub
+}
+
+func New(db *gorm.DB) *Notifier {
+	return &Notifier{DB: db, Hub: NewHub()}
+}
+
+// Enabled 用户是否接收某类通知
+func (n *Notifier) Enabled(userID uint, typ string) bool {
+	var pref models.NotificationPreference
+	if n.DB.Where("user_id = ? AND type = ?", userID, typ).First(&pref).Error != nil {
+		return true
+	}
+	return pref.Enabled
+}
+
+// Send 给单个用户发送通知；发给自己或用户关闭了该类型时忽略
+func (n *Notifier) Send(msg models.Notification) {
+	if msg.UserID == 0 || (msg.ActorID != nil && *msg.ActorID == msg.UserID) {
+		return
+	}
+	if !n.Enabled(msg.UserID, msg.Type) {
+		return
+	}
+	msg.Body = Excerpt(msg.Body)
+	if err := n.DB.Create(&msg).Error; err != nil {
+		return
+	}
+	n.Hub.publish(msg)
+}
+
+// Broadcast 给所有开启了该类型的用户发送通知
+func (n *Notifier) Broadcast(msg models.Notification) {
+	msg.Body = Excerpt(msg.Body)
+	disabled := n.DB.Model(&models.NotificationPreference{}).
+		Select("user_id").Where("type = ? AND enabled = ?", msg.Type, false)
+
+	var lastID uint
+	for {
+		var ids []uint
+		query := n.DB.Model(&models.User{}).Where("id > ? AND id NOT IN (?)", lastID, disabled)
+		if msg.ActorID != nil {
+			query = query.Where("id <> ?", *msg.ActorID)
+		}
+		query.Order("id ASC").Limit(broadcastBatch).Pluck("id", &ids)
+		if len(ids) == 0 {
+			return
+		}
+
+		batch := make([]models.Notification, len(ids))
+		for i, id := range ids {
+			batch[i] = msg
+			batch[i].UserID = id
+		}
+		n.DB.Create(&batch)
+		for _, item := range batch {
+			n.Hub.publish(item)
+		}
+		lastID = ids[len(ids)-1]
+	}
+}
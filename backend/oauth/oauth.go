@@ -0,0 +1,414 @@
+// Package oauth 实现通用的 OIDC / OAuth2 授权码登录（PKCE + state + nonce）。
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"hxzd-server/config"
+)
+
+// StateTTL 授权请求有效期
+const StateTTL = 10 * time.Minute
+
+const (
+	// maxPendingStates 同时保留的未完成授权请求上限，防止刷授权入口耗尽内存
+	maxPendingStates = 50_000
+	// stateSweepInterval 过期授权请求的清理间隔
+	stateSweepInterval = time.Minute
+)
+
+var (
+	ErrUnknownProvider = errors.New("未知的登录方式")
+	ErrInvalidState    = errors.New("登录请求无效或已过期，请重试")
+	ErrBusy            = errors.New("进行中的登录请求过多，请稍后再试")
+)
+
+// Identity 第三方账号信息
+type Identity struct {
+	Provider  string
+	Subject   string
+	Username  string
+	Email     string
+	AvatarURL string
+}
+
+// ProviderInfo 返回给前端的提供方列表
+type ProviderInfo struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+type pending struct {
+	provider   string
+	verifier   string
+	nonce      string
+	linkUserID uint
+	expires    time.Time
+}
+
+// Manager 管理所有提供方以及进行中的授权请求
+type Manager struct {
+	providers map[string]*Provider
+	order     []string
+
+	mu     sync.Mutex
+	states map[string]pending
+}
+
+func NewManager(cfg *config.Config) *Manager {
+	m := &Manager{providers: map[string]*Provider{}, states: map[string]pending{}}
+	for _, p := range cfg.OAuthProviders {
+		redirect := cfg.OAuthRedirectBase + "/api/auth/oauth/" + p.Name + "/callback"
+		m.providers[p.Name] = newProvider(p, redirect)
+		m.order = append(m.order, p.Name)
+	}
+	go m.sweepLoop()
+	return m
+}
+
+func (m *Manager) sweepLoop() {
+	ticker := time.NewTicker(stateSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.sweep()
+	}
+}
+
+// sweep 清理过期的授权请求
+func (m *Manager) sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, v := range m.states {
+		if now.After(v.expires) {
+			delete(m.states, k)
+		}
+	}
+}
+
+func (m *Manager) Providers() []ProviderInfo {
+	result := []ProviderInfo{}
+	for _, name := range m.order {
+		result = append(result, ProviderInfo{Name: name, Label: m.providers[name].cfg.Label})
+	}
+	return result
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Begin 生成授权地址与 state；linkUserID 非 0 时表示为已登录用户绑定第三方账号。
+// 调用方需把 state 保存到发起授权的浏览器（HttpOnly Cookie），回调时原样交给 Complete 比对
+func (m *Manager) Begin(ctx context.Context, name string, linkUserID uint) (string, string, error) {
+	p, ok := m.providers[name]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+	ep, err := p.resolve(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	m.mu.Lock()
+	if len(m.states) >= maxPendingStates {
+		m.mu.Unlock()
+		return "", "", ErrBusy
+	}
+	m.states[state] = pending{provider: name, verifier: verifier, nonce: nonce, linkUserID: linkUserID, expires: time.Now().Add(StateTTL)}
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.redirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:]))
+	q.Set("code_challenge_method", "S256")
+	if p.isOIDC() {
+		q.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(ep.Authorization, "?") {
+		sep = "&"
+	}
+	return ep.Authorization + sep + q.Encode(), state, nil
+}
+
+// Complete 处理回调：校验 state 与浏览器保存的 browserState 一致、换取令牌并解析第三方账号信息，
+// 防止把攻击者发起的授权回调注入受害者浏览器（登录 CSRF / 绑定劫持）
+func (m *Manager) Complete(ctx context.Context, name, state, browserState, code string) (*Identity, uint, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, 0, ErrInvalidState
+	}
+
+	m.mu.Lock()
+	pend, ok := m.states[state]
+	delete(m.states, state)
+	m.mu.Unlock()
+	if !ok || pend.provider != name || time.Now().After(pend.expires) {
+		return nil, 0, ErrInvalidState
+	}
+
+	p, ok := m.providers[name]
+	if !ok {
+		return nil, 0, ErrUnknownProvider
+	}
+	id, err := p.exchange(ctx, code, pend.verifier, pend.nonce)
+	if err != nil {
+		return nil, 0, err
+	}
+	return id, pend.linkUserID, nil
+}
+
+// ========== Provider ==========
+
+type endpoints struct {
+	Issuer        string `json:"issuer"`
+	Authorization string `json:"authorization_endpoint"`
+	Token         string `json:"token_endpoint"`
+	UserInfo      string `json:"userinfo_endpoint"`
+	JWKS          string `json:"jwks_uri"`
+}
+
+// Provider 单个提供方，端点在首次使用时解析
+type Provider struct {
+	cfg         config.OAuthProvider
+	redirectURL string
+	client      *http.Client
+
+	mu   sync.Mutex
+	ep   *endpoints
+	keys *keySet
+}
+
+func newProvider(cfg config.OAuthProvider, redirectURL string) *Provider {
+	return &Provider{cfg: cfg, redirectURL: redirectURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *Provider) isOIDC() bool {
+	for _, s := range p.cfg.Scopes {
+		if s == "openid" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) getJSON(ctx context.Context, rawURL, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", rawURL, resp.Status, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// resolve 合并手动配置的端点与 OIDC discovery 结果，手动配置优先
+func (p *Provider) resolve(ctx context.Context) (*endpoints, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ep != nil {
+		return p.ep, nil
+	}
+
+	ep := &endpoints{}
+	if p.cfg.Issuer != "" {
+		if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", "", ep); err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		if ep.Issuer != "" && strings.TrimSuffix(ep.Issuer, "/") != p.cfg.Issuer {
+			return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", ep.Issuer)
+		}
+		ep.Issuer = p.cfg.Issuer
+	}
+	if p.cfg.AuthURL != "" {
+		ep.Authorization = p.cfg.AuthURL
+	}
+	if p.cfg.TokenURL != "" {
+		ep.Token = p.cfg.TokenURL
+	}
+	if p.cfg.UserInfoURL != "" {
+		ep.UserInfo = p.cfg.UserInfoURL
+	}
+	if ep.Authorization == "" || ep.Token == "" {
+		return nil, fmt.Errorf("oauth provider %s: missing authorization or token endpoint", p.cfg.Name)
+	}
+	if ep.JWKS != "" {
+		p.keys = newKeySet(ep.JWKS, p.client)
+	}
+	p.ep = ep
+	return ep, nil
+}
+
+func (p *Provider) exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	ep, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ep.Token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("token exchange: %s: %s", resp.Status, body)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+
+	claims := map[string]interface{}{}
+	if token.IDToken != "" && p.keys != nil {
+		idClaims, err := p.verifyIDToken(ctx, token.IDToken, nonce)
+		if err != nil {
+			return nil, err
+		}
+		claims = idClaims
+	} else if p.isOIDC() && ep.UserInfo == "" {
+		return nil, errors.New("oidc: provider returned no verifiable id_token")
+	}
+
+	if ep.UserInfo != "" && token.AccessToken != "" {
+		info := map[string]interface{}{}
+		if err := p.getJSON(ctx, ep.UserInfo, token.AccessToken, &info); err != nil {
+			return nil, fmt.Errorf("userinfo: %w", err)
+		}
+		// userinfo 的 sub 必须与 id_token 一致
+		if sub, ok := claims["sub"]; ok && info["sub"] != nil && fmt.Sprint(info["sub"]) != fmt.Sprint(sub) {
+			return nil, errors.New("userinfo: subject mismatch")
+		}
+		for k, v := range info {
+			claims[k] = v
+		}
+	}
+
+	return p.mapIdentity(claims)
+}
+
+// claimString 读取字段，数字等非字符串值转为文本
+func claimString(claims map[string]interface{}, key string) string {
+	v, ok := claims[key]
+	if !ok || v == nil {
+		return ""
+	}
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%f", t), "0"), ".")
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// expandTemplate 将 {claim} 替换为字段值，任一字段缺失时返回空
+func expandTemplate(tpl string, claims map[string]interface{}) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(tpl, "{")
+		if start < 0 {
+			b.WriteString(tpl)
+			return b.String()
+		}
+		end := strings.Index(tpl[start:], "}")
+		if end < 0 {
+			b.WriteString(tpl)
+			return b.String()
+		}
+		v := claimString(claims, tpl[start+1:start+end])
+		if v == "" {
+			return ""
+		}
+		b.WriteString(tpl[:start])
+		b.WriteString(url.PathEscape(v))
+		tpl = tpl[start+end+1:]
+	}
+}
+
+func (p *Provider) mapIdentity(claims map[string]interface{}) (*Identity, error) {
+	id := &Identity{
+		Provider: p.cfg.Name,
+		Subject:  claimString(claims, p.cfg.SubjectClaim),
+		Username: claimString(claims, p.cfg.UsernameClaim),
+		Email:    claimString(claims, p.cfg.EmailClaim),
+	}
+	if id.Subject == "" {
+		return nil, fmt.Errorf("oauth: missing subject claim %q", p.cfg.SubjectClaim)
+	}
+	if id.Username == "" {
+		id.Username = claimString(claims, "name")
+	}
+	if strings.Contains(p.cfg.AvatarClaim, "{") {
+		id.AvatarURL = expandTemplate(p.cfg.AvatarClaim, claims)
+	} else {
+		id.AvatarURL = claimString(claims, p.cfg.AvatarClaim)
+	}
+	return id, nil
+}
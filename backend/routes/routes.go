@@ -1,13 +1,22 @@
 package routes
 
 import (
+	"log"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"hxzd-server/challenge"
 	"hxzd-server/config"
 	"hxzd-server/handlers"
+	"hxzd-server/mailer"
 	"hxzd-server/middleware"
+	"hxzd-server/notify"
+	"hxzd-server/oauth"
+	"hxzd-server/storage"
+	"hxzd-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,19 +25,49 @@ import (
 func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	staticDir := cfg.StaticDir
 
-	authHandler := handlers.NewAuthHandler(db, cfg)
-	announcementHandler := handlers.NewAnnouncementHandler(db)
-	forumHandler := handlers.NewForumHandler(db)
+	perms := utils.NewPermissionStore(db)
+	challenges := newChallengeManager(db, cfg)
+	hasher := utils.NewPasswordHasher(cfg)
+	policy := utils.NewPasswordPolicy(cfg)
+
+	authHandler := handlers.NewAuthHandler(db, cfg, perms, challenges, hasher, policy)
+	notifier := notify.New(db)
+	announcementHandler := handlers.NewAnnouncementHandler(db, notifier)
+	forumHandler := handlers.NewForumHandler(db, perms, challenges, notifier)
 	pageHandler := handlers.NewPageHandler(db)
 	settingsHandler := handlers.NewSettingsHandler(db)
 	serverStatusHandler := handlers.NewServerStatusHandler(db, cfg)
-	userHandler := handlers.NewUserHandler(db)
+	userHandler := handlers.NewUserHandler(db, perms, hasher, policy)
 	worldMapHandler := handlers.NewWorldMapHandler(db)
+	minecraftHandler := handlers.NewMinecraftHandler(db, cfg)
+	roleHandler := handlers.NewRoleHandler(db, perms)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, perms)
+	sanctionHandler := handlers.NewSanctionHandler(db, perms, notifier)
+	auditHandler := handlers.NewAuditHandler(db)
+	challengeHandler := handlers.NewChallengeHandler(challenges)
+	oauthHandler := handlers.NewOAuthHandler(db, cfg, oauth.NewManager(cfg))
+	searchHandler := handlers.NewSearchHandler(db, perms)
+	forumCategoryHandler := handlers.NewForumCategoryHandler(db, perms)
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init mailer: %v", err)
+	}
+	notificationHandler := handlers.NewNotificationHandler(db, notifier, notify.NewDigester(db, mail, cfg.SiteURL))
+	reportHandler := handlers.NewReportHandler(db, perms, notifier)
+	forumTrashHandler := handlers.NewForumTrashHandler(db)
+	forumTagHandler := handlers.NewForumTagHandler(db)
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init storage: %v", err)
+	}
+	attachmentHandler := handlers.NewAttachmentHandler(db, perms, store)
 
 	// ===== 静态文件 =====
 	r.Static("/css", filepath.Join(staticDir, "css"))
 	r.Static("/js", filepath.Join(staticDir, "js"))
 	r.Static("/assets", filepath.Join(staticDir, "assets"))
+	r.GET("/uploads/*key", attachmentHandler.Serve)
 
 	htmlPages := []string{
 		"index.html", "login.html", "forum.html", "admin.html",
@@ -52,18 +91,27 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 	})
 
 	// ===== API 路由 =====
+	optionalAuth := middleware.OptionalAuth(cfg, db)
 	api := r.Group("/api")
 	{
 		// 公开
 		api.POST("/auth/register", authHandler.Register)
 		api.POST("/auth/login", authHandler.Login)
+		api.POST("/auth/logout", authHandler.Logout)
+		api.GET("/challenges", challengeHandler.Issue)
+		api.GET("/auth/oauth/providers", oauthHandler.Providers)
+		api.GET("/auth/oauth/:provider/start", oauthHandler.Start)
+		api.GET("/auth/oauth/:provider/callback", oauthHandler.Callback)
 
 		api.GET("/announcements", announcementHandler.List)
 		api.GET("/announcements/latest", announcementHandler.Latest)
 		api.GET("/announcements/:id", announcementHandler.Get)
 
-		api.GET("/forum/posts", forumHandler.ListPosts)
-		api.GET("/forum/posts/:id", forumHandler.GetPost)
+		api.GET("/forum/categories", optionalAuth, forumCategoryHandler.ListCategories)
+		api.GET("/forum/tags", forumTagHandler.ListTags)
+		api.GET("/forum/tags/:name", forumTagHandler.GetTag)
+		api.GET("/forum/posts", optionalAuth, forumHandler.ListPosts)
+		api.GET("/forum/posts/:id", optionalAuth, forumHandler.GetPost)
 
 		api.GET("/pages/:slug", pageHandler.GetPage)
 		api.GET("/settings", settingsHandler.GetPublicSettings)
@@ -73,55 +121,135 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		api.GET("/server-status/:id", serverStatusHandler.GetStatusByID)
 
 		api.GET("/world-maps", worldMapHandler.ListMaps)
+		api.GET("/search", optionalAuth, searchHandler.Search)
+		api.GET("/reports/reasons", reportHandler.Reasons)
+
+		// 游戏内插件回调
+		plugin := api.Group("/plugin")
+		plugin.Use(middleware.PluginAuth(cfg.PluginSecret, db))
+		{
+			plugin.POST("/minecraft/link", minecraftHandler.ConfirmLink)
+		}
 
 		// 需要登录
 		auth := api.Group("")
-		auth.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+		auth.Use(middleware.AuthMiddleware(cfg, db))
+		auth.Use(middleware.RequireScope(utils.ScopeUser))
 		{
 			auth.GET("/auth/me", authHandler.Me)
 			auth.PUT("/auth/profile", authHandler.UpdateProfile)
 			auth.PUT("/auth/password", authHandler.ChangePassword)
+			auth.POST("/auth/minecraft/code", minecraftHandler.IssueCode)
+			auth.DELETE("/auth/minecraft", minecraftHandler.Unlink)
+			auth.GET("/auth/oauth/identities", oauthHandler.ListIdentities)
+			auth.POST("/auth/oauth/:provider/link", oauthHandler.Link)
+			auth.DELETE("/auth/oauth/:provider", oauthHandler.Unlink)
 
 			auth.POST("/forum/posts", forumHandler.CreatePost)
 			auth.PUT("/forum/posts/:id", forumHandler.UpdatePost)
 			auth.DELETE("/forum/posts/:id", forumHandler.DeletePost)
+			auth.GET("/forum/posts/:id/revisions", forumHandler.ListRevisions)
+			auth.POST("/forum/posts/:id/revisions/:revisionId/restore", forumHandler.RestoreRevision)
 			auth.POST("/forum/posts/:id/comments", forumHandler.CreateComment)
 			auth.DELETE("/forum/comments/:commentId", forumHandler.DeleteComment)
+			auth.POST("/forum/posts/:id/reactions", forumHandler.AddReaction)
+			auth.DELETE("/forum/posts/:id/reactions", forumHandler.RemoveReaction)
+			auth.POST("/forum/posts/:id/poll/vote", forumHandler.VotePoll)
+			auth.DELETE("/forum/posts/:id/poll/vote", forumHandler.UnvotePoll)
+			auth.POST("/forum/posts/:id/watch", forumHandler.WatchPost)
+			auth.DELETE("/forum/posts/:id/watch", forumHandler.UnwatchPost)
+			auth.POST("/forum/categories/:slug/watch", forumHandler.WatchCategory)
+			auth.DELETE("/forum/categories/:slug/watch", forumHandler.UnwatchCategory)
+			auth.POST("/forum/comments/:commentId/reactions", forumHandler.AddReaction)
+			auth.DELETE("/forum/comments/:commentId/reactions", forumHandler.RemoveReaction)
+
+			auth.POST("/reports", reportHandler.Create)
+
+			auth.GET("/attachments", attachmentHandler.List)
+			auth.POST("/attachments", attachmentHandler.Upload)
+			auth.DELETE("/attachments/:id", attachmentHandler.Delete)
+
+			auth.GET("/notifications", notificationHandler.List)
+			auth.GET("/notifications/stream", notificationHandler.Stream)
+			auth.POST("/notifications/read-all", notificationHandler.MarkAllRead)
+			auth.POST("/notifications/:id/read", notificationHandler.MarkRead)
+			auth.GET("/notifications/preferences", notificationHandler.GetPreferences)
+			auth.PUT("/notifications/preferences", notificationHandler.UpdatePreferences)
+			auth.GET("/notifications/email", notificationHandler.GetEmailDigest)
+			auth.PUT("/notifications/email", notificationHandler.UpdateEmailDigest)
+			auth.GET("/subscriptions", forumHandler.ListSubscriptions)
 		}
 
-		// 管理员
+		// 管理后台，按权限逐项授权
 		admin := api.Group("/admin")
-		admin.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-		admin.Use(middleware.AdminOnly())
+		admin.Use(middleware.AuthMiddleware(cfg, db))
 		{
-			admin.POST("/announcements", announcementHandler.Create)
-			admin.PUT("/announcements/:id", announcementHandler.Update)
-			admin.DELETE("/announcements/:id", announcementHandler.Delete)
-
-			admin.GET("/pages", pageHandler.ListPages)
-			admin.PUT("/pages/:slug", pageHandler.UpdatePage)
-
-			admin.GET("/settings", settingsHandler.GetAllSettings)
-			admin.PUT("/settings", settingsHandler.UpdateSettings)
-
-			admin.GET("/users", userHandler.ListUsers)
-			admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
-			admin.PUT("/users/:id/password", userHandler.ResetPassword)
-			admin.DELETE("/users/:id", userHandler.DeleteUser)
-
-			admin.GET("/server-status/config", serverStatusHandler.GetConfig)
-			admin.PUT("/server-status/config", serverStatusHandler.UpdateConfig)
-			admin.POST("/server-status/refresh", serverStatusHandler.RefreshStatus)
-
-			admin.GET("/servers", serverStatusHandler.ListServers)
-			admin.POST("/servers", serverStatusHandler.CreateServer)
-			admin.PUT("/servers/:id", serverStatusHandler.UpdateServer)
-			admin.DELETE("/servers/:id", serverStatusHandler.DeleteServer)
-
-			admin.GET("/world-maps", worldMapHandler.AdminListMaps)
-			admin.POST("/world-maps", worldMapHandler.CreateMap)
-			admin.PUT("/world-maps/:id", worldMapHandler.UpdateMap)
-			admin.DELETE("/world-maps/:id", worldMapHandler.DeleteMap)
+			can := func(p string) gin.HandlerFunc { return middleware.RequirePermission(perms, p) }
+
+			admin.POST("/announcements", can(utils.PermAnnouncementsManage), announcementHandler.Create)
+			admin.PUT("/announcements/:id", can(utils.PermAnnouncementsManage), announcementHandler.Update)
+			admin.DELETE("/announcements/:id", can(utils.PermAnnouncementsManage), announcementHandler.Delete)
+
+			admin.GET("/pages", can(utils.PermPagesManage), pageHandler.ListPages)
+			admin.PUT("/pages/:slug", can(utils.PermPagesManage), pageHandler.UpdatePage)
+
+			admin.GET("/settings", can(utils.PermSettingsManage), settingsHandler.GetAllSettings)
+			admin.PUT("/settings", can(utils.PermSettingsManage), settingsHandler.UpdateSettings)
+
+			admin.GET("/users", can(utils.PermUsersView), userHandler.ListUsers)
+			admin.PUT("/users/:id/role", can(utils.PermUsersRole), userHandler.UpdateUserRole)
+			admin.PUT("/users/:id/password", can(utils.PermUsersPassword), userHandler.ResetPassword)
+			admin.DELETE("/users/:id", can(utils.PermUsersDelete), userHandler.DeleteUser)
+
+			admin.GET("/sanctions", can(utils.PermUsersSanction), sanctionHandler.ListSanctions)
+			admin.POST("/users/:id/sanctions", can(utils.PermUsersSanction), sanctionHandler.CreateSanction)
+			admin.DELETE("/sanctions/:id", can(utils.PermUsersSanction), sanctionHandler.LiftSanction)
+
+			admin.GET("/permissions", can(utils.PermRolesManage), roleHandler.ListPermissions)
+			admin.GET("/roles", can(utils.PermRolesManage), roleHandler.ListRoles)
+			admin.POST("/roles", can(utils.PermRolesManage), roleHandler.CreateRole)
+			admin.PUT("/roles/:id", can(utils.PermRolesManage), roleHandler.UpdateRole)
+			admin.DELETE("/roles/:id", can(utils.PermRolesManage), roleHandler.DeleteRole)
+
+			admin.GET("/api-keys", can(utils.PermAPIKeysManage), apiKeyHandler.ListKeys)
+			admin.POST("/api-keys", can(utils.PermAPIKeysManage), apiKeyHandler.CreateKey)
+			admin.DELETE("/api-keys/:id", can(utils.PermAPIKeysManage), apiKeyHandler.DeleteKey)
+
+			admin.GET("/audit-logs", can(utils.PermAuditView), auditHandler.ListLogs)
+
+			admin.GET("/server-status/config", can(utils.PermServersManage), serverStatusHandler.GetConfig)
+			admin.PUT("/server-status/config", can(utils.PermServersManage), serverStatusHandler.UpdateConfig)
+			admin.POST("/server-status/refresh", can(utils.PermServersManage), serverStatusHandler.RefreshStatus)
+
+			admin.GET("/servers", can(utils.PermServersManage), serverStatusHandler.ListServers)
+			admin.POST("/servers", can(utils.PermServersManage), serverStatusHandler.CreateServer)
+			admin.PUT("/servers/:id", can(utils.PermServersManage), serverStatusHandler.UpdateServer)
+			admin.DELETE("/servers/:id", can(utils.PermServersManage), serverStatusHandler.DeleteServer)
+
+			admin.GET("/forum/categories", can(utils.PermForumCategories), forumCategoryHandler.AdminListCategories)
+			admin.POST("/forum/categories", can(utils.PermForumCategories), forumCategoryHandler.CreateCategory)
+			admin.PUT("/forum/categories/:id", can(utils.PermForumCategories), forumCategoryHandler.UpdateCategory)
+			admin.DELETE("/forum/categories/:id", can(utils.PermForumCategories), forumCategoryHandler.DeleteCategory)
+
+			admin.GET("/forum/tags", can(utils.PermForumModerate), forumTagHandler.AdminListTags)
+			admin.POST("/forum/tags", can(utils.PermForumModerate), forumTagHandler.CreateTag)
+			admin.PUT("/forum/tags/:id", can(utils.PermForumModerate), forumTagHandler.UpdateTag)
+			admin.POST("/forum/tags/:id/merge", can(utils.PermForumModerate), forumTagHandler.MergeTag)
+			admin.DELETE("/forum/tags/:id", can(utils.PermForumModerate), forumTagHandler.DeleteTag)
+
+			admin.GET("/reports", can(utils.PermReportsHandle), reportHandler.List)
+			admin.POST("/reports/:id/resolve", can(utils.PermReportsHandle), reportHandler.Resolve)
+
+			admin.GET("/forum/trash", can(utils.PermForumTrash), forumTrashHandler.ListTrash)
+			admin.POST("/forum/trash/posts/:id/restore", can(utils.PermForumTrash), forumTrashHandler.RestorePost)
+			admin.DELETE("/forum/trash/posts/:id", can(utils.PermForumTrash), forumTrashHandler.PurgePost)
+			admin.POST("/forum/trash/comments/:id/restore", can(utils.PermForumTrash), forumTrashHandler.RestoreComment)
+			admin.DELETE("/forum/trash/comments/:id", can(utils.PermForumTrash), forumTrashHandler.PurgeComment)
+
+			admin.GET("/world-maps", can(utils.PermMapsManage), worldMapHandler.AdminListMaps)
+			admin.POST("/world-maps", can(utils.PermMapsManage), worldMapHandler.CreateMap)
+			admin.PUT("/world-maps/:id", can(utils.PermMapsManage), worldMapHandler.UpdateMap)
+			admin.DELETE("/world-maps/:id", can(utils.PermMapsManage), worldMapHandler.DeleteMap)
 		}
 	}
 
@@ -134,3 +262,29 @@ func SetupRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
 	})
 }
+
+// newChallengeManager 注册人机验证提供方，是否启用由站点设置 challenge_<提供方>_<场景> 控制
+func newChallengeManager(db *gorm.DB, cfg *config.Config) *challenge.Manager {
+	difficulty := func() int {
+		d, err := strconv.Atoi(utils.GetSetting(db, "challenge_pow_difficulty", "16"))
+		if err != nil {
+			return 16
+		}
+		return d
+	}
+	providers := []challenge.Provider{
+		challenge.NewProofOfWork(difficulty, 10*time.Minute),
+		challenge.NewArithmeticCaptcha(5 * time.Minute),
+	}
+	if cfg.HCaptchaSecret != "" {
+		providers = append(providers, challenge.NewHCaptcha(cfg.HCaptchaSiteKey, cfg.HCaptchaSecret, cfg.HCaptchaVerifyURL))
+	}
+	if cfg.TurnstileSecret != "" {
+		providers = append(providers, challenge.NewTurnstile(cfg.TurnstileSiteKey, cfg.TurnstileSecret, cfg.TurnstileVerifyURL))
+	}
+
+	enabled := func(name, purpose string) bool {
+		return utils.GetSetting(db, "challenge_"+name+"_"+purpose, "false") == "true"
+	}
+	return challenge.NewManager(enabled, providers...)
+}
@@ -0,0 +1,46 @@
+// Package storage 保存上传的附件，支持本地目录与 S3 兼容的对象存储
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"hxzd-server/config"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("storage: object not found")
+
+// keyPattern 对象键只允许小写字母、数字与 / _ . -，由服务端生成
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9/_.-]*$`)
+
+// Storage 附件存储后端
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 读取对象，不存在时返回 ErrNotFound；调用方负责关闭
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// New 按 STORAGE_DRIVER 创建存储后端
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "", "local":
+		return NewLocal(cfg.StorageLocalDir), nil
+	case "s3":
+		return NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PathStyle)
+	}
+	return nil, fmt.Errorf("storage: unknown driver %q", cfg.StorageDriver)
+}
+
+func checkKey(key string) error {
+	if !keyPattern.MatchString(key) || strings.Contains(key, "..") || strings.Contains(key, "//") {
+		return fmt.Errorf("storage: invalid key %q", key)
+	}
+	return nil
+}
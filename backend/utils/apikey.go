@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const apiKeyPrefix = "hxzd_"
+
+// API 密钥作用域：除下列两项外，也可直接使用权限标识授予对应管理接口
+const (
+	ScopeUser   = "user"   // 以密钥所有者身份调用需登录的接口
+	ScopePlugin = "plugin" // 游戏内插件回调
+)
+
+// IsValidScope 判断作用域是否存在
+func IsValidScope(s string) bool {
+	return s == ScopeUser || s == ScopePlugin || IsValidPermission(s)
+}
+
+// GenerateAPIKey 返回明文密钥、用于展示的前缀以及存储用摘要
+func GenerateAPIKey() (plain, prefix, hash string, err error) {
+	buf := make([]byte, 24)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	plain = apiKeyPrefix + hex.EncodeToString(buf)
+	return plain, plain[:len(apiKeyPrefix)+6], HashAPIKey(plain), nil
+}
+
+// HashAPIKey 密钥本身为高熵随机串，直接使用 SHA-256 即可
+func HashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"unicode/utf8"
+
+	"hxzd-server/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 不参与差异比较的字段
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+
+	// 由 content 渲染得到
+	"content_html": true,
+}
+
+// auditMaxValueLen 超过该字节数的字符串只保留开头一段与长度、摘要，避免大段正文撑满审计记录
+const auditMaxValueLen = 1024
+
+// FieldChange 单个字段的变更
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+func toFieldMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if json.Unmarshal(raw, &m) != nil {
+		return nil
+	}
+	return m
+}
+
+// isNestedObject 关联对象（如 author）不参与比较
+func isNestedObject(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// summarizeValue 截断过长的字符串，附带原始长度与 SHA-256 前缀以便核对
+func summarizeValue(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || len(s) <= auditMaxValueLen {
+		return v
+	}
+	cut := auditMaxValueLen
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("%s…（共 %d 字节，sha256 %s）", s[:cut], len(s), hex.EncodeToString(sum[:8]))
+}
+
+// DiffFields 按 JSON 字段比较两个快照，只保留有变化的字段；过长的字符串以摘要形式保存
+func DiffFields(before, after interface{}) map[string]FieldChange {
+	b, a := toFieldMap(before), toFieldMap(after)
+	changes := map[string]FieldChange{}
+	for k, bv := range b {
+		if auditIgnoredFields[k] || isNestedObject(bv) {
+			continue
+		}
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(bv, av) {
+			changes[k] = FieldChange{Before: summarizeValue(bv), After: summarizeValue(a[k])}
+		}
+	}
+	for k, av := range a {
+		if auditIgnoredFields[k] || isNestedObject(av) {
+			continue
+		}
+		if _, ok := b[k]; !ok {
+			changes[k] = FieldChange{Before: nil, After: summarizeValue(av)}
+		}
+	}
+	return changes
+}
+
+// RecordAudit 记录一次管理操作，before/after 为操作前后的快照（新建时 before 为 nil，删除时 after 为 nil）
+func RecordAudit(db *gorm.DB, c *gin.Context, action, targetType string, targetID interface{}, before, after interface{}) {
+	// 不转义 < > &，避免 HTML 正文膨胀为 \u003c 之类的转义序列
+	var changes bytes.Buffer
+	enc := json.NewEncoder(&changes)
+	enc.SetEscapeHTML(false)
+	enc.Encode(DiffFields(before, after))
+
+	entry := models.AuditLog{
+		ActorID:    c.GetUint("user_id"),
+		ActorName:  c.GetString("username"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		Changes:    string(bytes.TrimSpace(changes.Bytes())),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if v, ok := c.Get("api_key_id"); ok {
+		id := v.(uint)
+		entry.APIKeyID = &id
+	}
+	if len(entry.UserAgent) > 512 {
+		entry.UserAgent = entry.UserAgent[:512]
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("[audit] failed to record %s on %s/%v: %v", action, targetType, targetID, err)
+	}
+}
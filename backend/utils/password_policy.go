@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"hxzd-server/config"
+)
+
+var ErrBreachedPassword = errors.New("该密码过于常见或已出现在泄露密码库中，请更换")
+
+// 内置的常见弱密码，未配置泄露库时也会检查
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "password", "password1",
+	"qwerty", "qwerty123", "abc123", "111111", "000000", "123123", "666666",
+	"888888", "admin", "admin123", "root", "iloveyou", "minecraft", "1qaz2wsx",
+	"a123456", "aa123456", "qq123456", "woaini1314", "123qwe", "letmein",
+}
+
+// PasswordPolicy 密码复杂度与泄露库检查
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int // 大写、小写、数字、符号中至少包含几类
+	breached   *BreachedList
+}
+
+func NewPasswordPolicy(cfg *config.Config) *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:  cfg.PasswordMinLength,
+		MinClasses: cfg.PasswordMinClasses,
+		breached:   LoadBreachedList(cfg.BreachedPasswordsPath),
+	}
+}
+
+func charClasses(password string) int {
+	var upper, lower, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, b := range []bool{upper, lower, digit, other} {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// Check 校验密码，identities 为不能与密码相同的用户名、Minecraft ID 等
+func (p *PasswordPolicy) Check(password string, identities ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("密码至少需要 %d 个字符", p.MinLength)
+	}
+	if charClasses(password) < p.MinClasses {
+		return fmt.Errorf("密码需要包含大写字母、小写字母、数字、符号中的至少 %d 类", p.MinClasses)
+	}
+	for _, id := range identities {
+		if id != "" && strings.EqualFold(password, id) {
+			return errors.New("密码不能与用户名或 Minecraft ID 相同")
+		}
+	}
+	if p.breached.Contains(password) {
+		return ErrBreachedPassword
+	}
+	return nil
+}
+
+// BreachedList 本地泄露密码库，按 SHA1 前 5 位分片（k-anonymity），格式与 Pwned Passwords range 接口一致：
+// 目录模式下 <dir>/<PREFIX>（或 .txt）每行 "SUFFIX:COUNT"；文件模式下每行为完整 SHA1（可带 ":COUNT"）
+type BreachedList struct {
+	dir    string
+	hashes map[string]map[string]bool // prefix -> suffix 集合
+}
+
+// LoadBreachedList path 为空时仅使用内置弱密码表
+func LoadBreachedList(path string) *BreachedList {
+	b := &BreachedList{hashes: map[string]map[string]bool{}}
+	for _, pw := range commonPasswords {
+		b.add(sha1Hex(pw))
+	}
+	if path == "" {
+		return b
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf("[password] breached list not loaded: %v", err)
+		return b
+	}
+	if info.IsDir() {
+		b.dir = path
+		return b
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("[password] breached list not loaded: %v", err)
+		return b
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) == 40 {
+			b.add(strings.ToUpper(hash))
+			n++
+		}
+	}
+	log.Printf("[password] loaded %d breached password hashes", n)
+	return b
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func (b *BreachedList) add(hash string) {
+	prefix, suffix := hash[:5], hash[5:]
+	if b.hashes[prefix] == nil {
+		b.hashes[prefix] = map[string]bool{}
+	}
+	b.hashes[prefix][suffix] = true
+}
+
+// Contains 判断密码是否在泄露库中
+func (b *BreachedList) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+	hash := sha1Hex(password)
+	prefix, suffix := hash[:5], hash[5:]
+	if b.hashes[prefix][suffix] {
+		return true
+	}
+	if b.dir == "" {
+		return false
+	}
+	return b.rangeFileContains(prefix, suffix)
+}
+
+func (b *BreachedList) rangeFileContains(prefix, suffix string) bool {
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		f, err := os.Open(filepath.Join(b.dir, name))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			s, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+			if strings.EqualFold(s, suffix) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"log"
+	"sync"
+
+	"hxzd-server/models"
+
+	"gorm.io/gorm"
+)
+
+// 权限标识
+const (
+	PermAll = "*"
+
+	PermAnnouncementsManage = "announcements.manage"
+	PermPagesManage         = "pages.manage"
+	PermSettingsManage      = "settings.manage"
+	PermUsersView           = "users.view"
+	PermUsersRole           = "users.role"
+	PermUsersPassword       = "users.password"
+	PermUsersDelete         = "users.delete"
+	PermUsersSanction       = "users.sanction"
+	PermRolesManage         = "roles.manage"
+	PermAPIKeysManage       = "apikeys.manage"
+	PermAuditView           = "audit.view"
+	PermServersManage       = "servers.manage"
+	PermMapsManage          = "maps.manage"
+	PermForumModerate       = "forum.moderate"
+	PermForumCategories     = "forum.categories"
+	PermReportsHandle       = "reports.handle"
+	PermForumTrash          = "forum.trash"
+)
+
+// AllPermissions 可分配的权限及说明
+var AllPermissions = []struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}{
+	{PermAnnouncementsManage, "管理公告"},
+	{PermPagesManage, "管理页面"},
+	{PermSettingsManage, "修改站点设置"},
+	{PermUsersView, "查看用户列表"},
+	{PermUsersRole, "修改用户角色"},
+	{PermUsersPassword, "重置用户密码"},
+	{PermUsersDelete, "删除用户"},
+	{PermUsersSanction, "封禁/禁言用户"},
+	{PermRolesManage, "管理角色"},
+	{PermAPIKeysManage, "管理 API 密钥"},
+	{PermAuditView, "查看审计日志"},
+	{PermServersManage, "管理游戏服务器与状态配置"},
+	{PermMapsManage, "管理世界地图"},
+	{PermForumModerate, "论坛版务（编辑/删除/置顶他人内容）"},
+	{PermForumCategories, "管理论坛分类"},
+	{PermReportsHandle, "处理举报（审核队列）"},
+	{PermForumTrash, "论坛回收站（恢复/彻底删除）"},
+}
+
+// IsValidPermission 判断权限标识是否存在
+func IsValidPermission(p string) bool {
+	if p == PermAll {
+		return true
+	}
+	for _, item := range AllPermissions {
+		if item.Key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionStore 缓存角色 → 权限映射，角色变更后需调用 Invalidate
+type PermissionStore struct {
+	DB    *gorm.DB
+	mu    sync.RWMutex
+	roles map[string]map[string]bool
+}
+
+func NewPermissionStore(db *gorm.DB) *PermissionStore {
+	return &PermissionStore{DB: db}
+}
+
+func (s *PermissionStore) load() map[string]map[string]bool {
+	s.mu.RLock()
+	roles := s.roles
+	s.mu.RUnlock()
+	if roles != nil {
+		return roles
+	}
+
+	var items []models.Role
+	if err := s.DB.Find(&items).Error; err != nil {
+		// 读取失败时不缓存，本次按无权限处理，下次请求重新加载
+		log.Printf("[permission] load roles: %v", err)
+		return map[string]map[string]bool{}
+	}
+	roles = make(map[string]map[string]bool, len(items))
+	for _, r := range items {
+		set := make(map[string]bool, len(r.Permissions))
+		for _, p := range r.Permissions {
+			set[p] = true
+		}
+		roles[r.Name] = set
+	}
+
+	s.mu.Lock()
+	s.roles = roles
+	s.mu.Unlock()
+	return roles
+}
+
+func (s *PermissionStore) Invalidate() {
+	s.mu.Lock()
+	s.roles = nil
+	s.mu.Unlock()
+}
+
+// RoleExists 判断角色是否存在
+func (s *PermissionStore) RoleExists(role string) bool {
+	_, ok := s.load()[role]
+	return ok
+}
+
+// Has 判断角色是否拥有任一给定权限
+func (s *PermissionStore) Has(role string, perms ...string) bool {
+	set := s.load()[role]
+	if set == nil {
+		return false
+	}
+	if set[PermAll] {
+		return true
+	}
+	for _, p := range perms {
+		if set[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions 返回角色拥有的全部权限（"*" 展开为完整列表）
+func (s *PermissionStore) Permissions(role string) []string {
+	set := s.load()[role]
+	result := []string{}
+	if set[PermAll] {
+		for _, item := range AllPermissions {
+			result = append(result, item.Key)
+		}
+		return result
+	}
+	for _, item := range AllPermissions {
+		if set[item.Key] {
+			result = append(result, item.Key)
+		}
+	}
+	return result
+}
+
+// Covers 判断 actor 角色是否拥有 target 角色的全部权限，防止越权分配
+func (s *PermissionStore) Covers(actor, target string) bool {
+	roles := s.load()
+	actorSet, targetSet := roles[actor], roles[target]
+	if actorSet == nil {
+		return false
+	}
+	if actorSet[PermAll] {
+		return true
+	}
+	for p := range targetSet {
+		if !actorSet[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// Grants 判断 actor 角色是否拥有给定的全部权限，"*" 只有超级权限角色才能授予
+func (s *PermissionStore) Grants(actor string, perms []string) bool {
+	actorSet := s.load()[actor]
+	if actorSet == nil {
+		return false
+	}
+	if actorSet[PermAll] {
+		return true
+	}
+	for _, p := range perms {
+		if !actorSet[p] {
+			return false
+		}
+	}
+	return true
+}
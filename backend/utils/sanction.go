@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"time"
+
+	"hxzd-server/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SanctionBan  = "ban"
+	SanctionMute = "mute"
+)
+
+// activeSanctionScope 未解除且未过期的处罚
+func activeSanctionScope(db *gorm.DB) *gorm.DB {
+	return db.Where("lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+}
+
+// ActiveSanction 返回用户当前生效的指定类型处罚，没有则返回 nil
+func ActiveSanction(db *gorm.DB, userID uint, kind string) *models.UserSanction {
+	var s models.UserSanction
+	err := activeSanctionScope(db).
+		Where("user_id = ? AND type = ?", userID, kind).
+		Order("expires_at IS NULL DESC, expires_at DESC").
+		First(&s).Error
+	if err != nil {
+		return nil
+	}
+	return &s
+}
+
+// ActiveSanctions 返回用户当前生效的全部处罚
+func ActiveSanctions(db *gorm.DB, userID uint) []models.UserSanction {
+	var items []models.UserSanction
+	activeSanctionScope(db).Where("user_id = ?", userID).Order("created_at DESC").Find(&items)
+	return items
+}
+
+// SanctionInfo 返回给被处罚用户的说明
+func SanctionInfo(s *models.UserSanction) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       s.Type,
+		"reason":     s.Reason,
+		"expires_at": s.ExpiresAt,
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"hxzd-server/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SessionCookieName = "hxzd_session"
+	CSRFCookieName    = "hxzd_csrf"
+	CSRFHeaderName    = "X-CSRF-Token"
+
+	OAuthStateCookieName = "hxzd_oauth_state"
+	// oauthStateCookiePath 只在第三方登录回调时发送
+	oauthStateCookiePath = "/api/auth/oauth/"
+)
+
+func sameSiteMode(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func setCookie(c *gin.Context, cfg *config.Config, name, value string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   cfg.CookieSecure,
+		HttpOnly: httpOnly,
+		SameSite: sameSiteMode(cfg.CookieSameSite),
+	})
+}
+
+// SetSessionCookies 写入 HttpOnly 会话 Cookie，以及可被前端读取的 CSRF 令牌 Cookie
+func SetSessionCookies(c *gin.Context, cfg *config.Config, token string) error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	maxAge := int(cfg.JWTExpiry.Seconds())
+	setCookie(c, cfg, SessionCookieName, token, maxAge, true)
+	setCookie(c, cfg, CSRFCookieName, hex.EncodeToString(buf), maxAge, false)
+	return nil
+}
+
+// ClearSessionCookies 退出登录
+func ClearSessionCookies(c *gin.Context, cfg *config.Config) {
+	setCookie(c, cfg, SessionCookieName, "", -1, true)
+	setCookie(c, cfg, CSRFCookieName, "", -1, false)
+}
+
+// SetOAuthStateCookie 把授权请求的 state 绑定到当前浏览器；回调是第三方站点发起的顶层跳转，
+// Strict 模式下不会携带 Cookie，因此至少使用 Lax
+func SetOAuthStateCookie(c *gin.Context, cfg *config.Config, state string, maxAge int) {
+	sameSite := sameSiteMode(cfg.CookieSameSite)
+	if sameSite == http.SameSiteStrictMode {
+		sameSite = http.SameSiteLaxMode
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     OAuthStateCookieName,
+		Value:    state,
+		Path:     oauthStateCookiePath,
+		Domain:   cfg.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+// ClearOAuthStateCookie 回调处理后清除 state Cookie
+func ClearOAuthStateCookie(c *gin.Context, cfg *config.Config) {
+	SetOAuthStateCookie(c, cfg, "", -1)
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"hxzd-server/models"
+
+	"gorm.io/gorm"
+)
+
+// GetSetting 读取站点设置，不存在时返回 fallback
+func GetSetting(db *gorm.DB, key, fallback string) string {
+	var setting models.SiteSetting
+	if db.Where("`key` = ?", key).First(&setting).Error != nil {
+		return fallback
+	}
+	return setting.Value
+}
@@ -29,6 +29,9 @@ function initAdminNav() {
         dashboard: loadDashboard,
         announcements: loadAnnouncements,
         forum: loadForumAdmin,
+        tags: loadTags,
+        reports: loadReports,
+        trash: loadTrash,
         users: loadUsers,
         pages: loadPages,
         settings: loadSettings,
@@ -176,6 +179,233 @@ async function adminDeletePost(id) {
   HXZD.toast('已删除');
 }
 
+// ===== 标签管理 =====
+async function loadTags() {
+  const wrap = document.getElementById('tagsTable');
+  try {
+    const res = await HXZD.authFetch('/admin/forum/tags');
+    const tags = await res.json();
+    if (!res.ok) {
+      wrap.innerHTML = `<p style="color:var(--sao-danger)">${esc(tags.error || '加载失败')}</p>`;
+      return;
+    }
+    if (!tags.length) {
+      wrap.innerHTML = '<p style="color:var(--sao-text-muted);padding:20px">暂无标签</p>';
+      return;
+    }
+    wrap.innerHTML = `<table class="admin-table"><thead><tr>
+      <th>ID</th><th>标签</th><th>说明</th><th>帖子数</th><th>推荐</th><th>操作</th>
+    </tr></thead><tbody>${tags.map(t => `<tr>
+      <td>${t.id}</td>
+      <td><a href="forum.html?tag=${encodeURIComponent(t.name)}" target="_blank">#${esc(t.name)}</a></td>
+      <td>${esc(t.description || '—')}</td>
+      <td>${t.post_count}</td>
+      <td>${t.curated ? '★' : '—'}</td>
+      <td class="actions">
+        <button onclick="updateTag(${t.id}, { curated: ${!t.curated} })">${t.curated ? '取消推荐' : '设为推荐'}</button>
+        <button onclick="renameTag(${t.id}, '${esc(t.name)}')">重命名</button>
+        <button onclick="describeTag(${t.id})">说明</button>
+        <button onclick="mergeTag(${t.id}, '${esc(t.name)}')">合并到…</button>
+        <button class="btn-del" onclick="deleteTag(${t.id}, '${esc(t.name)}')">删除</button>
+      </td>
+    </tr>`).join('')}</tbody></table>`;
+  } catch (e) {
+    wrap.innerHTML = '<p style="color:var(--sao-danger)">加载失败</p>';
+  }
+}
+
+async function tagRequest(url, method, body, done) {
+  const res = await HXZD.authFetch(url, { method, body });
+  const data = await res.json();
+  if (!res.ok) {
+    HXZD.toast(data.error || '操作失败');
+    return;
+  }
+  HXZD.toast(done);
+  loadTags();
+}
+
+async function createTag() {
+  const input = document.getElementById('newTagName');
+  if (!input.value.trim()) return;
+  await tagRequest('/admin/forum/tags', 'POST', { name: input.value, curated: true }, '已创建');
+  input.value = '';
+}
+
+function updateTag(id, body) {
+  tagRequest(`/admin/forum/tags/${id}`, 'PUT', body, '已保存');
+}
+
+function renameTag(id, name) {
+  const next = prompt('新的标签名（已有同名标签时请使用合并）', name);
+  if (next && next.trim() !== name) updateTag(id, { name: next });
+}
+
+function describeTag(id) {
+  const description = prompt('标签说明（显示在标签页）');
+  if (description !== null) updateTag(id, { description });
+}
+
+async function mergeTag(id, name) {
+  const into = prompt(`把 #${name} 合并到哪个标签？（输入标签名）`);
+  if (!into) return;
+  const res = await fetch(HXZD.API + '/forum/tags/' + encodeURIComponent(into.trim()));
+  const target = await res.json();
+  if (!res.ok) {
+    HXZD.toast(target.error || '目标标签不存在');
+    return;
+  }
+  if (!confirm(`#${name} 的帖子将改用 #${target.name}，原标签删除，确定继续？`)) return;
+  tagRequest(`/admin/forum/tags/${id}/merge`, 'POST', { into_id: target.id }, '已合并');
+}
+
+function deleteTag(id, name) {
+  if (!confirm(`确定删除标签 #${name}？帖子上的该标签会一并移除。`)) return;
+  tagRequest(`/admin/forum/tags/${id}`, 'DELETE', undefined, '已删除');
+}
+
+// ===== 举报审核 =====
+const reportActions = {
+  dismiss: '驳回', hide: '隐藏', delete: '删除', warn: '警告作者', ban: '封禁作者',
+};
+const reportTargetLabels = { post: '帖子', comment: '评论', user: '用户' };
+let reportReasonLabels = null;
+
+async function loadReports() {
+  const wrap = document.getElementById('reportsTable');
+  try {
+    if (!reportReasonLabels) {
+      const reasons = await (await fetch(HXZD.API + '/reports/reasons')).json();
+      reportReasonLabels = Object.fromEntries(reasons.map(r => [r.key, r.label]));
+    }
+    const status = document.getElementById('reportStatus').value;
+    const res = await HXZD.authFetch('/admin/reports?size=50&status=' + status);
+    const data = await res.json();
+    if (!res.ok) {
+      wrap.innerHTML = `<p style="color:var(--sao-danger)">${esc(data.error || '加载失败')}</p>`;
+      return;
+    }
+    if (!data.reports.length) {
+      wrap.innerHTML = '<p style="color:var(--sao-text-muted);padding:20px">暂无举报</p>';
+      return;
+    }
+    wrap.innerHTML = `<table class="admin-table"><thead><tr>
+      <th>ID</th><th>对象</th><th>内容</th><th>原因</th><th>举报人</th><th>时间</th><th>处理</th>
+    </tr></thead><tbody>${data.reports.map(r => {
+      const t = r.target || {};
+      const title = t.exists
+        ? (t.link ? `<a href="${esc(t.link)}" target="_blank">${esc(t.title)}</a>` : esc(t.title))
+        : '<span style="color:var(--sao-text-muted)">已删除</span>';
+      const handled = r.status === 'pending'
+        ? Object.entries(reportActions)
+          .filter(([k]) => r.target_type !== 'user' || (k !== 'hide' && k !== 'delete'))
+          .map(([k, label]) => `<button class="${k === 'dismiss' ? '' : 'btn-del'}" onclick="resolveReport(${r.id}, '${k}')">${label}</button>`).join('')
+        : `${reportActions[r.action] || r.status} · ${esc(r.handled_by?.username || '—')}<br><small>${r.handled_at ? HXZD.formatDateTime(r.handled_at) : ''}${r.note ? ' · ' + esc(r.note) : ''}</small>`;
+      return `<tr>
+        <td>${r.id}</td>
+        <td>${reportTargetLabels[r.target_type] || r.target_type}${t.reports > 1 ? ` <small>(${t.reports} 条)</small>` : ''}${t.is_hidden ? ' <small>已隐藏</small>' : ''}</td>
+        <td>${title}<br><small>${esc(t.author_name || '')}${t.excerpt ? '：' + esc(t.excerpt) : ''}</small></td>
+        <td>${esc(reportReasonLabels[r.reason] || r.reason)}${r.detail ? `<br><small>${esc(r.detail)}</small>` : ''}</td>
+        <td>${esc(r.reporter?.username || '—')}</td>
+        <td>${HXZD.formatDateTime(r.created_at)}</td>
+        <td class="actions">${handled}</td>
+      </tr>`;
+    }).join('')}</tbody></table>`;
+  } catch (e) {
+    wrap.innerHTML = '<p style="color:var(--sao-danger)">加载失败</p>';
+  }
+}
+
+async function resolveReport(id, action) {
+  const body = { action };
+  if (action !== 'dismiss') {
+    const note = prompt(`${reportActions[action]}：处理说明（会发送给作者，可留空）`);
+    if (note === null) return;
+    body.note = note;
+  }
+  if (action === 'ban') {
+    const duration = prompt('封禁时长，例如 72h，留空为永久', '72h');
+    if (duration === null) return;
+    body.duration = duration.trim();
+  }
+  const res = await HXZD.authFetch(`/admin/reports/${id}/resolve`, { method: 'POST', body });
+  const data = await res.json();
+  if (!res.ok) {
+    HXZD.toast(data.error || '处理失败');
+    return;
+  }
+  HXZD.toast('已处理');
+  loadReports();
+}
+
+// ===== 回收站 =====
+async function loadTrash() {
+  const wrap = document.getElementById('trashTable');
+  const type = document.getElementById('trashType').value;
+  try {
+    const res = await HXZD.authFetch('/admin/forum/trash?size=50&type=' + type);
+    const data = await res.json();
+    if (!res.ok) {
+      wrap.innerHTML = `<p style="color:var(--sao-danger)">${esc(data.error || '加载失败')}</p>`;
+      return;
+    }
+    const items = (type === 'comment' ? data.comments : data.posts) || [];
+    if (!items.length) {
+      wrap.innerHTML = '<p style="color:var(--sao-text-muted);padding:20px">回收站为空</p>';
+      return;
+    }
+    const kind = type === 'comment' ? 'comments' : 'posts';
+    wrap.innerHTML = `<table class="admin-table"><thead><tr>
+      <th>ID</th><th>${type === 'comment' ? '所属帖子 / 内容' : '标题'}</th><th>作者</th><th>删除者</th><th>原因</th><th>删除时间</th><th>操作</th>
+    </tr></thead><tbody>${items.map(it => `<tr>
+      <td>${it.id}</td>
+      <td>${type === 'comment'
+        ? `${esc(it.post_title)}${it.post_deleted ? ' <small>(帖子已删除)</small>' : ''}<br><small>${esc((it.content || '').slice(0, 100))}</small>`
+        : esc(it.title)}</td>
+      <td>${esc(it.author?.username || '—')}</td>
+      <td>${esc(it.deleted_by?.username || '—')}</td>
+      <td>${esc(it.delete_reason || '—')}</td>
+      <td>${HXZD.formatDateTime(it.deleted_at)}</td>
+      <td class="actions">
+        <button onclick="restoreTrash('${kind}', ${it.id})">恢复</button>
+        <button class="btn-del" onclick="purgeTrash('${kind}', ${it.id})">彻底删除</button>
+      </td>
+    </tr>`).join('')}</tbody></table>`;
+  } catch (e) {
+    wrap.innerHTML = '<p style="color:var(--sao-danger)">加载失败</p>';
+  }
+}
+
+async function restoreTrash(kind, id, category) {
+  const query = category ? '?category=' + encodeURIComponent(category) : '';
+  const res = await HXZD.authFetch(`/admin/forum/trash/${kind}/${id}/restore${query}`, { method: 'POST' });
+  const data = await res.json();
+  if (!res.ok) {
+    // 原分类已删除时询问恢复到哪个分类
+    if (kind === 'posts' && !category && res.status === 400 && data.error.includes('分类')) {
+      const slug = prompt(data.error + '（输入分类标识，例如 general）', 'general');
+      if (slug) restoreTrash(kind, id, slug.trim());
+      return;
+    }
+    HXZD.toast(data.error || '恢复失败');
+    return;
+  }
+  HXZD.toast('已恢复');
+  loadTrash();
+}
+
+async function purgeTrash(kind, id) {
+  if (!confirm('彻底删除后无法恢复，确定继续？')) return;
+  const res = await HXZD.authFetch(`/admin/forum/trash/${kind}/${id}`, { method: 'DELETE' });
+  if (!res.ok) {
+    const data = await res.json();
+    HXZD.toast(data.error || '删除失败');
+    return;
+  }
+  HXZD.toast('已彻底删除');
+  loadTrash();
+}
+
 // ===== 用户管理 =====
 async function loadUsers() {
   try {
@@ -208,9 +438,10 @@ async function toggleRole(id, newRole) {
 }
 
 async function resetUserPwd(id) {
-  const pwd = prompt('输入新密码（至少6位）：');
-  if (!pwd || pwd.length < 6) { alert('密码至少6位'); return; }
-  await HXZD.authFetch(`/admin/users/${id}/password`, { method: 'PUT', body: { new_password: pwd } });
+  const pwd = prompt('输入新密码（至少8位，含字母与数字）：');
+  if (!pwd) return;
+  const res = await HXZD.authFetch(`/admin/users/${id}/password`, { method: 'PUT', body: { new_password: pwd } });
+  if (!res.ok) { const data = await res.json(); alert(data.error || '重置失败'); return; }
   HXZD.toast('密码已重置');
 }
 
@@ -233,7 +464,7 @@ async function loadPages() {
       <div class="page-editor-card">
         <h3>📄 ${esc(p.title || p.slug)} <small style="color:var(--sao-text-muted);font-weight:400">(${p.slug})</small></h3>
         <div class="sao-input-group"><label>页面标题</label><input type="text" id="pageTitle_${p.slug}" value="${esc(p.title || '')}"></div>
-        <div class="sao-input-group"><label>内容 (HTML)</label><textarea id="pageContent_${p.slug}">${esc(p.content || '')}</textarea></div>
+        <div class="sao-input-group"><label>内容 (Markdown，可混用 HTML)</label><textarea id="pageContent_${p.slug}">${esc(p.content || '')}</textarea><button type="button" class="attach-btn" onclick="HXZD.pickAttachment(document.getElementById('pageContent_${p.slug}'))">📎 上传图片 / 文件</button></div>
         <button class="sao-submit-btn btn-small" onclick="savePage('${p.slug}')">保存</button>
       </div>
     `).join('');
@@ -8,6 +8,13 @@ document.addEventListener('DOMContentLoaded', () => {
   initAuthPage();
 });
 
+// 注册人机验证的结果收集函数
+let registerChallenge = null;
+
+async function refreshRegisterChallenge() {
+  registerChallenge = await HXZD.prepareChallenges('register', document.getElementById('registerChallenge'));
+}
+
 function initAuthPage() {
   // Tab 切换
   document.querySelectorAll('.auth-tab').forEach(tab => {
@@ -18,6 +25,7 @@ function initAuthPage() {
       document.getElementById('loginForm').style.display = target === 'login' ? 'block' : 'none';
       document.getElementById('registerForm').style.display = target === 'register' ? 'block' : 'none';
       document.getElementById('profileForm').style.display = target === 'profile' ? 'block' : 'none';
+      if (target === 'register') refreshRegisterChallenge();
       document.getElementById('authTitle').textContent =
         target === 'login' ? 'USER LOGIN' : target === 'register' ? 'USER REGISTER' : 'USER PROFILE';
     });
@@ -41,7 +49,7 @@ function initAuthPage() {
       });
       const data = await res.json();
       if (!res.ok) {
-        errEl.textContent = data.error || '登录失败';
+        errEl.textContent = HXZD.errorText(data, '登录失败');
         return;
       }
       HXZD.saveAuth(data.token, data.user);
@@ -65,6 +73,7 @@ function initAuthPage() {
     }
 
     try {
+      const challenge = registerChallenge ? await registerChallenge() : {};
       const res = await fetch(HXZD.API + '/auth/register', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
@@ -73,11 +82,13 @@ function initAuthPage() {
           password: form.password.value,
           email: form.email.value,
           minecraft_id: form.minecraft_id.value,
+          challenge,
         }),
       });
       const data = await res.json();
       if (!res.ok) {
         errEl.textContent = data.error || '注册失败';
+        refreshRegisterChallenge();
         return;
       }
       HXZD.saveAuth(data.token, data.user);
@@ -88,12 +99,55 @@ function initAuthPage() {
     }
   });
 
+  loadOAuthProviders();
+  if (handleOAuthRedirect()) return;
+
   // 如果已登录，直接显示个人资料
   if (HXZD.isLoggedIn()) {
     showProfile();
   }
 }
 
+// 第三方登录按钮
+async function loadOAuthProviders() {
+  try {
+    const res = await fetch(HXZD.API + '/auth/oauth/providers');
+    const providers = await res.json();
+    document.getElementById('oauthProviders').innerHTML = providers.map(p => `
+      <a class="sao-submit-btn btn-small" href="${HXZD.API}/auth/oauth/${encodeURIComponent(p.name)}/start">
+        使用 ${HXZD.escapeHtml(p.label)} 登录
+      </a>`).join('');
+  } catch (e) {
+    console.error(e);
+  }
+}
+
+// 处理第三方登录回调写入 URL fragment 的结果，返回是否已接管页面
+function handleOAuthRedirect() {
+  const params = new URLSearchParams(location.hash.slice(1));
+  if (![...params.keys()].some(k => k.startsWith('oauth_'))) return false;
+  history.replaceState(null, '', location.pathname);
+
+  if (params.get('oauth_error')) {
+    document.getElementById('loginError').textContent = params.get('oauth_error');
+    return false;
+  }
+  if (params.get('oauth_linked')) {
+    HXZD.toast('第三方账号已绑定');
+    return false;
+  }
+  const token = params.get('oauth_token') || '';
+  if (!token && !params.get('oauth_session')) return false;
+
+  HXZD.saveAuth(token, null);
+  HXZD.authFetch('/auth/me').then(res => res.json()).then(user => {
+    HXZD.saveAuth(token, user);
+    HXZD.toast('登录成功！');
+    showProfile();
+  });
+  return true;
+}
+
 async function showProfile() {
   // 隐藏登录和注册 tab，只显示个人资料
   document.querySelectorAll('.auth-tab').forEach(t => {
@@ -138,6 +192,10 @@ async function showProfile() {
       avatarEl.innerHTML = `<span>${user.username.charAt(0).toUpperCase()}</span>`;
     }
 
+    if (user.must_change_password) {
+      document.getElementById('profileError').textContent = '当前仍在使用默认/弱密码，请立即修改密码';
+    }
+
     // 管理员入口
     if (user.role === 'admin') {
       document.getElementById('adminEntryBtn').style.display = 'block';
@@ -184,8 +242,8 @@ async function changePassword() {
     errEl.textContent = '请填写密码';
     return;
   }
-  if (newPwd.length < 6) {
-    errEl.textContent = '新密码至少6位';
+  if (newPwd.length < 8) {
+    errEl.textContent = '新密码至少8位';
     return;
   }
   try {
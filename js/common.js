@@ -17,8 +17,9 @@ const HXZD = {
   },
 
   // 保存登录信息
+  // Cookie 会话模式下 token 为空，凭证保存在 HttpOnly Cookie 中
   saveAuth(token, user) {
-    localStorage.setItem('hxzd_token', token);
+    localStorage.setItem('hxzd_token', token || '');
     localStorage.setItem('hxzd_user', JSON.stringify(user));
   },
 
@@ -26,17 +27,107 @@ const HXZD = {
   clearAuth() {
     localStorage.removeItem('hxzd_token');
     localStorage.removeItem('hxzd_user');
+    fetch(this.API + '/auth/logout', { method: 'POST' }).catch(() => {});
   },
 
   // 是否已登录
   isLoggedIn() {
-    return !!this.getToken();
+    return !!this.getToken() || !!this.getUser();
   },
 
-  // 是否管理员
+  // 是否可进入管理面板（拥有任一管理权限）
   isAdmin() {
     const u = this.getUser();
-    return u && u.role === 'admin';
+    return !!u && (u.role === 'admin' || (u.permissions || []).length > 0);
+  },
+
+  // 是否拥有指定权限
+  can(perm) {
+    const u = this.getUser();
+    return !!u && (u.permissions || []).includes(perm);
+  },
+
+  // 错误提示文本，封禁/禁言时附带原因与到期时间
+  errorText(data, fallback) {
+    let msg = (data && data.error) || fallback;
+    const s = data && data.sanction;
+    if (s) {
+      msg += '：' + (s.reason || '无');
+      msg += s.expires_at ? '（至 ' + new Date(s.expires_at).toLocaleString() + '）' : '（永久）';
+    }
+    return msg;
+  },
+
+  // 人机验证：拉取并渲染挑战，返回 collect() 用于提交前收集结果（挑战一次性有效，失败后需重新调用）
+  async prepareChallenges(purpose, container) {
+    const collectors = {};
+    container.innerHTML = '';
+    try {
+      const res = await fetch(this.API + '/challenges?purpose=' + purpose);
+      const data = await res.json();
+      for (const ch of data.challenges || []) {
+        const p = ch.params;
+        if (ch.name === 'pow') {
+          const answer = this.solvePow(p.salt, p.difficulty);
+          collectors.pow = async () => ({ id: p.id, answer: await answer });
+        } else if (ch.name === 'captcha') {
+          container.insertAdjacentHTML('beforeend', `
+            <div class="sao-input-group">
+              <label>验证码</label>
+              <img src="${p.image}" alt="captcha" style="display:block;margin-bottom:6px;border-radius:4px">
+              <input type="text" data-captcha required inputmode="numeric" placeholder="请输入计算结果">
+            </div>`);
+          const input = container.querySelector('[data-captcha]');
+          collectors.captcha = async () => ({ id: p.id, answer: input.value.trim() });
+        } else if (ch.name === 'hcaptcha' || ch.name === 'turnstile') {
+          const cls = ch.name === 'hcaptcha' ? 'h-captcha' : 'cf-turnstile';
+          const src = ch.name === 'hcaptcha'
+            ? 'https://js.hcaptcha.com/1/api.js'
+            : 'https://challenges.cloudflare.com/turnstile/v0/api.js';
+          container.insertAdjacentHTML('beforeend',
+            `<div class="${cls}" data-sitekey="${this.escapeHtml(p.site_key)}"></div>`);
+          if (!document.querySelector(`script[src="${src}"]`)) {
+            const script = document.createElement('script');
+            script.src = src;
+            script.async = true;
+            document.head.appendChild(script);
+          }
+          const field = ch.name === 'hcaptcha' ? 'h-captcha-response' : 'cf-turnstile-response';
+          collectors[ch.name] = async () => {
+            const el = container.querySelector(`[name="${field}"]`);
+            return { token: el ? el.value : '' };
+          };
+        }
+      }
+    } catch (e) {
+      console.error(e);
+    }
+    return async () => {
+      const out = {};
+      for (const [name, fn] of Object.entries(collectors)) out[name] = await fn();
+      return out;
+    };
+  },
+
+  // 工作量证明：寻找 nonce 使 sha256(salt + nonce) 前导零比特数 >= difficulty
+  async solvePow(salt, difficulty) {
+    const enc = new TextEncoder();
+    for (let nonce = 0; ; nonce++) {
+      const buf = new Uint8Array(await crypto.subtle.digest('SHA-256', enc.encode(salt + nonce)));
+      let zeros = 0;
+      for (const b of buf) {
+        if (b === 0) { zeros += 8; continue; }
+        zeros += Math.clz32(b) - 24;
+        break;
+      }
+      if (zeros >= difficulty) return String(nonce);
+    }
+  },
+
+  // 读取 Cookie 会话模式下的 CSRF 令牌
+  getCSRFToken() {
+    const m = document.cookie.match(/(?:^|;\s*)hxzd_csrf=([^;]+)/);
+    return m ? decodeURIComponent(m[1]) : '';
   },
 
   // 带认证的 fetch
@@ -48,6 +139,10 @@ const HXZD = {
         'Authorization': 'Bearer ' + token,
       };
     }
+    const csrf = this.getCSRFToken();
+    if (csrf) {
+      options.headers = { ...options.headers, 'X-CSRF-Token': csrf };
+    }
     if (options.body && typeof options.body === 'object' && !(options.body instanceof FormData)) {
       options.headers = { ...options.headers, 'Content-Type': 'application/json' };
       options.body = JSON.stringify(options.body);
@@ -59,6 +154,42 @@ const HXZD = {
     return res;
   },
 
+  // 选择文件上传为附件，并在输入框光标处插入 Markdown 引用
+  pickAttachment(textarea) {
+    const input = document.createElement('input');
+    input.type = 'file';
+    input.accept = 'image/jpeg,image/png,image/gif,image/webp,.pdf,.zip,.txt';
+    input.onchange = async () => {
+      const file = input.files[0];
+      if (!file) return;
+      const form = new FormData();
+      form.append('file', file);
+      this.toast('上传中...');
+      try {
+        const res = await this.authFetch('/attachments', { method: 'POST', body: form });
+        const data = await res.json();
+        if (!res.ok) {
+          this.toast(this.errorText(data, '上传失败'));
+          return;
+        }
+        const name = data.filename.replace(/[\[\]]/g, '');
+        let md;
+        if (data.mime_type.startsWith('image/')) {
+          md = data.thumb_url ? `[![${name}](${data.thumb_url})](${data.url})` : `![${name}](${data.url})`;
+        } else {
+          md = `[📎 ${name}](${data.url})`;
+        }
+        const pos = textarea.selectionStart ?? textarea.value.length;
+        textarea.value = textarea.value.slice(0, pos) + md + textarea.value.slice(textarea.selectionEnd ?? pos);
+        textarea.focus();
+        textarea.selectionStart = textarea.selectionEnd = pos + md.length;
+      } catch (e) {
+        this.toast('网络错误');
+      }
+    };
+    input.click();
+  },
+
   // 初始化导航栏登录状态
   initNav() {
     const btn = document.getElementById('navAuthBtn');
@@ -68,6 +199,7 @@ const HXZD = {
       btn.querySelector('.sao-btn-text').textContent = user.username;
       btn.querySelector('.sao-btn-icon').textContent = '●';
     }
+    if (user) this.initNotifications(btn);
     // 高亮当前页
     const currentPage = location.pathname.split('/').pop() || 'index.html';
     document.querySelectorAll('.sao-btn[data-index]').forEach(link => {
@@ -77,6 +209,152 @@ const HXZD = {
     });
   },
 
+  // 站内通知：导航栏按钮、下拉列表与实时推送
+  initNotifications(authBtn) {
+    if (document.getElementById('navNotifyBtn')) return;
+    authBtn.insertAdjacentHTML('beforebegin', `
+      <a href="#" class="sao-btn" id="navNotifyBtn"><span class="sao-btn-icon">✉</span><span class="sao-btn-text">通知</span><span class="notify-badge" id="notifyBadge"></span><span class="sao-btn-diamond"></span></a>`);
+    document.body.insertAdjacentHTML('beforeend', `
+      <div class="notify-panel sao-panel" id="notifyPanel">
+        <div class="notify-panel-header">
+          <span>通知</span>
+          <button type="button" class="notify-read-all" id="notifyReadAll">全部已读</button>
+        </div>
+        <div class="notify-list" id="notifyList"></div>
+        <div class="notify-panel-footer" id="notifyEmail" style="display:none">
+          <label for="notifyEmailFrequency">邮件通知</label>
+          <select class="sao-select" id="notifyEmailFrequency"></select>
+        </div>
+      </div>`);
+    const panel = document.getElementById('notifyPanel');
+    document.getElementById('navNotifyBtn').addEventListener('click', e => {
+      e.preventDefault();
+      panel.classList.toggle('open');
+      if (panel.classList.contains('open')) {
+        this.loadNotifications();
+        this.loadEmailDigest();
+      }
+    });
+    document.getElementById('notifyEmailFrequency').addEventListener('change', async e => {
+      const res = await this.authFetch('/notifications/email', { method: 'PUT', body: { frequency: e.target.value } });
+      const data = await res.json();
+      if (!res.ok) {
+        this.toast(this.errorText(data, '设置失败'));
+        this.loadEmailDigest();
+        return;
+      }
+      this.toast('邮件通知设置已保存');
+    });
+    document.getElementById('notifyReadAll').addEventListener('click', async () => {
+      const res = await this.authFetch('/notifications/read-all', { method: 'POST' });
+      if (res.ok) this.loadNotifications();
+    });
+    document.getElementById('notifyList').addEventListener('click', async e => {
+      const item = e.target.closest('.notify-item');
+      if (!item) return;
+      if (item.classList.contains('unread')) {
+        const res = await this.authFetch('/notifications/' + item.dataset.id + '/read', { method: 'POST' });
+        if (res.ok) this.setUnread((await res.json()).unread);
+        item.classList.remove('unread');
+      }
+      if (item.dataset.link) location.href = item.dataset.link;
+    });
+
+    const user = this.getUser();
+    this.setUnread(user && user.unread_notifications);
+    this.streamNotifications();
+  },
+
+  setUnread(count) {
+    const badge = document.getElementById('notifyBadge');
+    if (!badge) return;
+    badge.textContent = count > 99 ? '99+' : (count || '');
+    badge.classList.toggle('show', count > 0);
+  },
+
+  async loadNotifications() {
+    const list = document.getElementById('notifyList');
+    try {
+      const res = await this.authFetch('/notifications?size=20');
+      if (!res.ok) return;
+      const data = await res.json();
+      this.setUnread(data.unread);
+      if (!data.notifications.length) {
+        list.innerHTML = '<div class="notify-empty">暂无通知</div>';
+        return;
+      }
+      list.innerHTML = data.notifications.map(n => `
+        <div class="notify-item${n.read_at ? '' : ' unread'}" data-id="${n.id}" data-link="${this.escapeHtml(n.link)}">
+          <div class="notify-title">${this.escapeHtml(n.title)}</div>
+          ${n.body ? `<div class="notify-body">${this.escapeHtml(n.body)}</div>` : ''}
+          <div class="notify-time">${this.formatDateTime(n.created_at)}</div>
+        </div>`).join('');
+    } catch (e) {
+      console.error(e);
+    }
+  },
+
+  // 邮件频率设置，服务器未配置邮件时隐藏
+  async loadEmailDigest() {
+    const box = document.getElementById('notifyEmail');
+    try {
+      const res = await this.authFetch('/notifications/email');
+      if (!res.ok) return;
+      const data = await res.json();
+      box.style.display = data.enabled ? 'flex' : 'none';
+      document.getElementById('notifyEmailFrequency').innerHTML = data.frequencies.map(f =>
+        `<option value="${f.key}"${f.key === data.frequency ? ' selected' : ''}>${this.escapeHtml(f.label)}</option>`).join('');
+    } catch (e) {
+      console.error(e);
+    }
+  },
+
+  // 通过 fetch 读取 SSE，令牌放在请求头而不是 URL 中；断开后延迟重连
+  async streamNotifications() {
+    let retry = 5000;
+    while (this.isLoggedIn()) {
+      try {
+        const res = await this.authFetch('/notifications/stream');
+        if (!res.ok || !res.body) break;
+        retry = 5000;
+        const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+        let buf = '';
+        for (;;) {
+          const { value, done } = await reader.read();
+          if (done) break;
+          buf += value;
+          let idx;
+          while ((idx = buf.indexOf('\n\n')) >= 0) {
+            this.handleStreamEvent(buf.slice(0, idx));
+            buf = buf.slice(idx + 2);
+          }
+        }
+      } catch (e) {
+        retry = Math.min(retry * 2, 60000);
+      }
+      await new Promise(r => setTimeout(r, retry));
+    }
+  },
+
+  handleStreamEvent(raw) {
+    let event = 'message';
+    let data = '';
+    for (const line of raw.split('\n')) {
+      if (line.startsWith('event:')) event = line.slice(6).trim();
+      else if (line.startsWith('data:')) data += line.slice(5).trim();
+    }
+    if (!data) return;
+    const payload = JSON.parse(data);
+    if (event === 'ready') {
+      this.setUnread(payload.unread);
+    } else if (event === 'notification') {
+      const badge = document.getElementById('notifyBadge');
+      this.setUnread((parseInt(badge.textContent, 10) || 0) + 1);
+      this.toast(payload.title);
+      if (document.getElementById('notifyPanel').classList.contains('open')) this.loadNotifications();
+    }
+  },
+
   // 加载背景图片
   async loadBackground() {
     try {
@@ -4,62 +4,174 @@
 
 let currentPage = 1;
 let currentCategory = '';
+let categories = [];
+let currentSort = 'latest';
+let currentReplies = '';
+let currentAuthor = '';
+// 标签筛选，多个标签时按 currentTagMode（any / all）组合
+let currentTags = [];
+let currentTagMode = 'any';
+let reactionEmojis = [];
+// 各回应栏当前的数据，键为 "post-1" / "comment-2"
+const reactionState = {};
 
 document.addEventListener('DOMContentLoaded', () => {
   HXZD.initNav();
-  HXZD.loadBackground();
+  HXZD.loadBackground().then(settings => {
+    reactionEmojis = (settings?.forum_reactions || '').split(',').map(e => e.trim()).filter(Boolean);
+  });
+
+  document.getElementById('forumSort').addEventListener('change', e => {
+    currentSort = e.target.value;
+    currentPage = 1;
+    loadPosts();
+  });
+  document.getElementById('forumReplies').addEventListener('change', e => {
+    currentReplies = e.target.value;
+    currentPage = 1;
+    loadPosts();
+  });
 
   // 如果已登录，显示发帖按钮
   if (HXZD.isLoggedIn()) {
     document.getElementById('newPostBtn').style.display = 'inline-flex';
   }
 
+  const params = new URLSearchParams(location.search);
+  if (params.get('tag')) currentTags = [params.get('tag')];
+  document.querySelectorAll('.tag-input').forEach(bindTagInput);
+
+  loadCategories();
+  loadPosts();
+
+  // 通知链接形如 /forum?post=1#comment-2
+  const postId = new URLSearchParams(location.search).get('post');
+  if (postId) {
+    viewPost(postId).then(() => {
+      const target = location.hash && document.getElementById(location.hash.slice(1));
+      if (target) target.scrollIntoView({ block: 'center' });
+    });
+  }
+});
+
+async function loadCategories() {
+  try {
+    const res = await HXZD.authFetch('/forum/categories');
+    categories = await res.json();
+  } catch (e) {
+    categories = [];
+  }
+
+  const bar = document.getElementById('forumCategories');
+  bar.innerHTML = '<button class="forum-cat-btn active" data-cat="">全部</button>' + categories.map(c => `
+    <button class="forum-cat-btn" data-cat="${HXZD.escapeHtml(c.slug)}" title="${HXZD.escapeHtml(c.description || '')}">
+      ${c.icon ? HXZD.escapeHtml(c.icon) + ' ' : ''}${HXZD.escapeHtml(c.name)}
+    </button>
+  `).join('');
+
   // 分类切换
-  document.querySelectorAll('.forum-cat-btn').forEach(btn => {
+  bar.querySelectorAll('.forum-cat-btn').forEach(btn => {
     btn.addEventListener('click', () => {
-      document.querySelectorAll('.forum-cat-btn').forEach(b => b.classList.remove('active'));
+      bar.querySelectorAll('.forum-cat-btn').forEach(b => b.classList.remove('active'));
       btn.classList.add('active');
       currentCategory = btn.dataset.cat;
       currentPage = 1;
+      updateWatchCategoryBtn();
       loadPosts();
     });
   });
 
-  loadPosts();
-});
+  document.getElementById('newPostCategory').innerHTML = categoryOptions('');
+}
+
+// 选中具体分类时显示关注按钮
+function updateWatchCategoryBtn() {
+  const btn = document.getElementById('watchCategoryBtn');
+  const cat = categories.find(c => c.slug === currentCategory);
+  if (!cat || !HXZD.isLoggedIn()) {
+    btn.style.display = 'none';
+    return;
+  }
+  btn.style.display = 'inline-flex';
+  btn.textContent = cat.watching ? '★ 已关注分类' : '☆ 关注分类';
+  btn.classList.toggle('active', cat.watching);
+}
+
+async function toggleWatchCategory() {
+  const cat = categories.find(c => c.slug === currentCategory);
+  if (!cat) return;
+  try {
+    const res = await HXZD.authFetch(`/forum/categories/${encodeURIComponent(cat.slug)}/watch`, { method: cat.watching ? 'DELETE' : 'POST' });
+    const data = await res.json();
+    if (!res.ok) {
+      HXZD.toast(HXZD.errorText(data, '操作失败'));
+      return;
+    }
+    cat.watching = data.watching;
+    updateWatchCategoryBtn();
+    HXZD.toast(data.watching ? '已关注，该分类有新帖子时会通知你' : '已取消关注');
+  } catch (e) {
+    HXZD.toast('网络错误');
+  }
+}
+
+function categoryName(slug) {
+  const cat = categories.find(c => c.slug === slug);
+  return cat ? cat.name : slug;
+}
+
+// 只列出可发帖的分类；编辑时保留帖子当前所在分类
+function categoryOptions(selected) {
+  return categories
+    .filter(c => c.can_post || c.slug === selected)
+    .map(c => `<option value="${HXZD.escapeHtml(c.slug)}" ${c.slug === selected ? 'selected' : ''}>${HXZD.escapeHtml(c.name)}</option>`)
+    .join('');
+}
 
 async function loadPosts() {
   const container = document.getElementById('forumPostList');
   container.innerHTML = '<div class="loading-placeholder">加载中...</div>';
 
   try {
-    let url = `/forum/posts?page=${currentPage}&size=15`;
-    if (currentCategory) url += `&category=${currentCategory}`;
-    const res = await fetch(HXZD.API + url);
+    let url = `/forum/posts?page=${currentPage}&size=15&sort=${currentSort}`;
+    if (currentCategory) url += `&category=${encodeURIComponent(currentCategory)}`;
+    if (currentReplies) url += `&has_replies=${currentReplies}`;
+    if (currentAuthor) url += `&author=${encodeURIComponent(currentAuthor)}`;
+    if (currentTags.length) url += `&tags=${encodeURIComponent(currentTags.join(','))}&tag_mode=${currentTagMode}`;
+    // 只筛选一个标签时显示标签页信息
+    const tagInfo = currentTags.length === 1
+      ? HXZD.authFetch('/forum/tags/' + encodeURIComponent(currentTags[0])).then(r => r.ok ? r.json() : null).catch(() => null)
+      : null;
+    const res = await HXZD.authFetch(url);
     const data = await res.json();
 
+    const filterTag = renderTagFilter(await tagInfo) +
+      (currentAuthor ? `<div class="forum-filter-tag">只看 ${HXZD.escapeHtml(currentAuthor)} 的帖子 <button onclick="filterByAuthor('')">✕</button></div>` : '');
     if (!data.posts || data.posts.length === 0) {
-      container.innerHTML = '<div class="loading-placeholder">暂无帖子，快来发第一帖吧！</div>';
+      container.innerHTML = filterTag + '<div class="loading-placeholder">暂无帖子，快来发第一帖吧！</div>';
       document.getElementById('forumPagination').innerHTML = '';
       return;
     }
 
-    const catLabels = { general: '综合', discussion: '讨论', question: '求助', showcase: '展示', suggestion: '建议', whitelist: '白名单申请' };
-
     container.innerHTML = data.posts.map(p => `
       <div class="forum-post-item ${p.is_pinned ? 'pinned' : ''}" onclick="viewPost(${p.id})">
         <div class="forum-post-title">
           ${p.is_pinned ? '<span style="color:var(--sao-gold)">📌 </span>' : ''}
           ${HXZD.escapeHtml(p.title)}
+          ${p.is_hidden ? '<span class="post-hidden-tag">已隐藏</span>' : ''}
         </div>
         <div class="forum-post-meta">
-          <span class="forum-post-cat">${catLabels[p.category] || p.category}</span>
-          <span>${HXZD.escapeHtml(p.author?.username || '匿名')}</span>
+          <span class="forum-post-cat">${HXZD.escapeHtml(categoryName(p.category))}</span>
+          ${renderPostTags(p.tags)}
+          <span class="forum-post-author" onclick="event.stopPropagation(); filterByAuthor(this.textContent)">${HXZD.escapeHtml(p.author?.username || '匿名')}</span>
           <span>👁 ${p.view_count || 0}</span>
-          <span>${HXZD.formatDate(p.created_at)}</span>
+          <span>💬 ${p.comment_count || 0}</span>
+          ${p.reaction_count ? `<span>✦ ${p.reaction_count}</span>` : ''}
+          <span>${HXZD.formatDate(currentSort === 'activity' ? p.last_activity_at : p.created_at)}</span>
         </div>
       </div>
     `).join('');
+    container.insertAdjacentHTML('afterbegin', filterTag);
 
     // 分页
     const totalPages = Math.ceil(data.total / data.size);
@@ -78,6 +190,84 @@ async function loadPosts() {
   }
 }
 
+// ===== 标签 =====
+function renderPostTags(tags) {
+  return (tags || []).map(t =>
+    `<span class="post-tag" data-tag="${HXZD.escapeHtml(t.name)}" onclick="event.stopPropagation(); filterByTag(this.dataset.tag)">#${HXZD.escapeHtml(t.name)}</span>`).join('');
+}
+
+function renderTagFilter(info) {
+  if (!currentTags.length) return '';
+  let html = `<div class="forum-filter-tag">标签 ${currentTags.map((t, i) =>
+    `<span class="post-tag">#${HXZD.escapeHtml(t)} <button onclick="removeTagFilter(${i})">✕</button></span>`).join(' ')}`;
+  if (currentTags.length > 1) {
+    html += ` <select class="sao-select forum-tag-mode" onchange="setTagMode(this.value)">
+      <option value="any"${currentTagMode === 'any' ? ' selected' : ''}>包含任一标签</option>
+      <option value="all"${currentTagMode === 'all' ? ' selected' : ''}>包含全部标签</option>
+    </select>`;
+  }
+  html += '</div>';
+  if (info) {
+    html += `<div class="forum-tag-page">
+      <span class="forum-tag-page-name">#${HXZD.escapeHtml(info.name)}</span>
+      <span>${info.post_count} 篇帖子${info.curated ? ' · 推荐标签' : ''}</span>
+      ${info.description ? `<p>${HXZD.escapeHtml(info.description)}</p>` : ''}
+    </div>`;
+  }
+  return html;
+}
+
+function filterByTag(name) {
+  if (!currentTags.includes(name)) currentTags.push(name);
+  currentPage = 1;
+  backToList();
+}
+
+function removeTagFilter(index) {
+  currentTags.splice(index, 1);
+  currentPage = 1;
+  loadPosts();
+}
+
+function setTagMode(mode) {
+  currentTagMode = mode;
+  currentPage = 1;
+  loadPosts();
+}
+
+function splitTags(value) {
+  return value.split(/[,，]/).map(s => s.trim()).filter(Boolean);
+}
+
+// 标签输入框：对最后一个逗号之后的内容做补全
+function bindTagInput(input) {
+  const list = document.getElementById('tagSuggestions');
+  let timer;
+  input.addEventListener('input', () => {
+    clearTimeout(timer);
+    timer = setTimeout(async () => {
+      const parts = input.value.split(/[,，]/);
+      const q = parts.pop().trim();
+      const chosen = parts.map(s => s.trim()).filter(Boolean);
+      const prefix = chosen.length ? chosen.join(', ') + ', ' : '';
+      try {
+        const res = await HXZD.authFetch('/forum/tags?limit=10&q=' + encodeURIComponent(q));
+        const tags = await res.json();
+        list.innerHTML = tags.filter(t => !chosen.includes(t.name)).map(t =>
+          `<option value="${HXZD.escapeHtml(prefix + t.name)}">${t.curated ? '★ ' : ''}${t.post_count} 篇帖子</option>`).join('');
+      } catch (e) {
+        list.innerHTML = '';
+      }
+    }, 200);
+  });
+}
+
+function filterByAuthor(name) {
+  currentAuthor = name;
+  currentPage = 1;
+  loadPosts();
+}
+
 function goPage(p) {
   currentPage = p;
   loadPosts();
@@ -95,11 +285,15 @@ async function viewPost(id) {
   commentsEl.innerHTML = '';
 
   try {
-    const res = await fetch(HXZD.API + `/forum/posts/${id}`);
+    const res = await HXZD.authFetch(`/forum/posts/${id}?view=tree`);
     const post = await res.json();
+    if (!res.ok) {
+      detailEl.innerHTML = `<div class="loading-placeholder">${HXZD.escapeHtml(HXZD.errorText(post, '加载失败'))}</div>`;
+      return;
+    }
     const user = HXZD.getUser();
-    const canDelete = user && (user.id === post.author_id || user.role === 'admin');
-    const canEdit = user && (user.id === post.author_id || user.role === 'admin');
+    const canDelete = user && (user.id === post.author_id || HXZD.can('forum.moderate'));
+    const canEdit = user && (user.id === post.author_id || HXZD.can('forum.moderate'));
 
     detailEl.innerHTML = `
       <div class="post-header">
@@ -108,24 +302,28 @@ async function viewPost(id) {
           <span>👤 ${HXZD.escapeHtml(post.author?.username || '匿名')}</span>
           <span>📅 ${HXZD.formatDateTime(post.created_at)}</span>
           <span>👁 ${post.view_count}</span>
+          ${post.edited_at ? `<span class="post-edited" title="最后编辑于 ${HXZD.formatDateTime(post.edited_at)}"${canEdit ? ` onclick="showRevisions(${post.id})"` : ''}>✎ 已编辑</span>` : ''}
           ${canEdit ? `<span><button class="sao-submit-btn btn-small" onclick="showEditPost(${post.id})" style="padding:2px 8px;font-size:0.7rem">编辑</button></span>` : ''}
-          ${canDelete ? `<span><button class="sao-submit-btn btn-small btn-danger" onclick="deletePost(${post.id})" style="padding:2px 8px;font-size:0.7rem">删除</button></span>` : ''}
+          ${canDelete ? `<span><button class="sao-submit-btn btn-small btn-danger" onclick="deletePost(${post.id}, ${user && user.id !== post.author_id})" style="padding:2px 8px;font-size:0.7rem">删除</button></span>` : ''}
+          ${user ? `<span><button class="sao-submit-btn btn-small btn-secondary" id="watchPostBtn" data-watching="${post.watching}" onclick="toggleWatchPost(${post.id})" style="padding:2px 8px;font-size:0.7rem">${post.watching ? '★ 已关注' : '☆ 关注'}</button></span>` : ''}
+          ${user && user.id !== post.author_id ? `<span><button class="sao-submit-btn btn-small btn-secondary" onclick="showReportForm('post', ${post.id})" style="padding:2px 8px;font-size:0.7rem">举报</button></span>` : ''}
+          ${post.is_hidden ? '<span class="post-hidden-tag">已隐藏</span>' : ''}
         </div>
       </div>
-      <div class="post-body" id="postBody">${HXZD.escapeHtml(post.content)}</div>
+      ${post.tags?.length ? `<div class="post-tags">${renderPostTags(post.tags)}</div>` : ''}
+      <div class="post-body rich-content" id="postBody">${post.content_html || ''}</div>
+      ${post.poll ? `<div class="poll" id="postPoll">${renderPoll(post.id, post.poll)}</div>` : ''}
+      <div class="post-revisions" id="postRevisions" style="display:none"></div>
+      ${renderReactions('post', post.id, post.reactions)}
       <div class="post-edit-form" id="postEditForm" style="display:none;padding:20px">
         <div class="sao-input-group"><label>标题</label><input type="text" id="editPostTitle" value="${HXZD.escapeHtml(post.title)}"></div>
         <div class="sao-input-group"><label>分类</label>
-          <select id="editPostCategory" class="sao-select">
-            <option value="general" ${post.category==='general'?'selected':''}>综合</option>
-            <option value="discussion" ${post.category==='discussion'?'selected':''}>讨论</option>
-            <option value="question" ${post.category==='question'?'selected':''}>求助</option>
-            <option value="showcase" ${post.category==='showcase'?'selected':''}>展示</option>
-            <option value="suggestion" ${post.category==='suggestion'?'selected':''}>建议</option>
-            <option value="whitelist" ${post.category==='whitelist'?'selected':''}>白名单申请</option>
-          </select>
+          <select id="editPostCategory" class="sao-select">${categoryOptions(post.category)}</select>
         </div>
-        <div class="sao-input-group"><label>内容</label><textarea id="editPostContent" rows="10">${HXZD.escapeHtml(post.content)}</textarea></div>
+        <div class="sao-input-group"><label>内容</label><textarea id="editPostContent" rows="10">${HXZD.escapeHtml(post.content)}</textarea>
+          <button type="button" class="attach-btn" onclick="HXZD.pickAttachment(document.getElementById('editPostContent'))">📎 上传图片 / 文件</button></div>
+        <div class="sao-input-group"><label>标签（用逗号分隔）</label><input type="text" id="editPostTags" class="tag-input" list="tagSuggestions" autocomplete="off" value="${HXZD.escapeHtml((post.tags || []).map(t => t.name).join(', '))}"></div>
+        <div class="sao-input-group"><label>编辑原因（可选）</label><input type="text" id="editPostReason" maxlength="255"></div>
         <div style="display:flex;gap:10px">
           <button class="sao-submit-btn btn-small" onclick="submitEditPost(${post.id})">保存修改</button>
           <button class="sao-submit-btn btn-small btn-secondary" onclick="cancelEditPost()">取消</button>
@@ -133,66 +331,311 @@ async function viewPost(id) {
       </div>
     `;
 
-    // 评论
+    // 评论（嵌套结构）
     const comments = post.comments || [];
+    const countComments = list => list.reduce((n, c) => n + (c.is_deleted ? 0 : 1) + countComments(c.replies || []), 0);
     let commHTML = `
-      <div class="sao-panel-header"><span class="sao-panel-diamond"></span><span>COMMENTS (${comments.length})</span></div>
+      <div class="sao-panel-header"><span class="sao-panel-diamond"></span><span>COMMENTS (${countComments(comments)})</span></div>
+      ${renderComments(comments, id, user)}
     `;
 
-    comments.forEach(c => {
-      const canDelComment = user && (user.id === c.author_id || user.role === 'admin');
-      commHTML += `
-        <div class="comment-item">
-          <div class="comment-author">${HXZD.escapeHtml(c.author?.username || '匿名')}
-            ${canDelComment ? `<button onclick="deleteComment(${c.id}, ${id})" style="margin-left:8px;background:none;border:none;color:var(--sao-danger);cursor:pointer;font-size:0.7rem">删除</button>` : ''}
-          </div>
-          <div class="comment-body">${HXZD.escapeHtml(c.content)}</div>
-          <div class="comment-time">${HXZD.formatDateTime(c.created_at)}</div>
-        </div>
-      `;
-    });
-
     if (HXZD.isLoggedIn()) {
       commHTML += `
+        <div class="comment-target" id="commentTarget" style="display:none"></div>
         <div class="comment-form">
           <textarea id="commentInput" placeholder="写下你的评论..."></textarea>
+          <button type="button" class="attach-btn" title="上传图片 / 文件" onclick="HXZD.pickAttachment(document.getElementById('commentInput'))">📎</button>
           <button class="sao-submit-btn btn-small" onclick="submitComment(${id})">发送</button>
         </div>
       `;
     }
 
     commentsEl.innerHTML = commHTML;
+    const tagInput = document.getElementById('editPostTags');
+    if (tagInput) bindTagInput(tagInput);
   } catch (e) {
     detailEl.innerHTML = '<div class="loading-placeholder">加载失败</div>';
   }
 }
 
+function renderComments(list, postId, user) {
+  return list.map(c => {
+    const canDelComment = !c.is_deleted && user && (user.id === c.author_id || HXZD.can('forum.moderate'));
+    const author = c.is_deleted ? '[deleted]' : HXZD.escapeHtml(c.author?.username || '匿名');
+    const replyTo = c.reply_to_user ? `<span class="comment-reply-to">回复 @${HXZD.escapeHtml(c.reply_to_user.username)}</span>` : '';
+    const quote = c.quote ? `<div class="comment-quote">${HXZD.escapeHtml(c.quote.is_deleted ? '[deleted]' : (c.quote.author?.username || '匿名') + '：' + c.quote.content)}</div>` : '';
+    const actions = !c.is_deleted && HXZD.isLoggedIn() ? `
+      <div class="comment-actions">
+        <button onclick="setCommentTarget('parent', ${c.id})">回复</button>
+        <button onclick="setCommentTarget('quote', ${c.id})">引用</button>
+        ${canDelComment ? `<button onclick="deleteComment(${c.id}, ${postId}, ${user.id !== c.author_id})" style="color:var(--sao-danger)">删除</button>` : ''}
+        ${user && user.id !== c.author_id ? `<button onclick="showReportForm('comment', ${c.id})">举报</button>` : ''}
+      </div>` : '';
+    const replies = c.replies && c.replies.length ? `<div class="comment-replies">${renderComments(c.replies, postId, user)}</div>` : '';
+    return `
+      <div class="comment-item ${c.is_deleted ? 'deleted' : ''}" id="comment-${c.id}">
+        <div class="comment-author"><span class="comment-author-name">${author}</span>${replyTo}</div>
+        ${quote}
+        <div class="comment-body rich-content">${commentBody(c)}</div>
+        <div class="comment-time">${HXZD.formatDateTime(c.created_at)}</div>
+        ${c.is_deleted ? '' : renderReactions('comment', c.id, c.reactions)}
+        ${actions}
+        ${replies}
+      </div>
+    `;
+  }).join('');
+}
+
+function commentBody(c) {
+  if (c.is_deleted) return '[deleted]';
+  if (c.is_hidden && !c.content_html) return '<span class="comment-hidden">该评论因被举报已隐藏</span>';
+  return (c.is_hidden ? '<span class="post-hidden-tag">已隐藏</span>' : '') + (c.content_html || '');
+}
+
+// ===== 举报 =====
+let reportReasons = null;
+
+// ===== 投票 =====
+// 投票后或截止后显示结果，公开投票附带投票人
+function renderPoll(postId, poll) {
+  const voted = poll.my_votes.length > 0;
+  const canVote = HXZD.isLoggedIn() && !poll.closed && !voted;
+  const total = poll.options.reduce((n, o) => n + o.vote_count, 0);
+  const status = [
+    poll.multiple ? '多选' : '单选',
+    poll.anonymous ? '匿名' : '公开',
+    `${poll.voter_count} 人参与`,
+    poll.closed ? '已截止' : (poll.ends_at ? `截止于 ${HXZD.formatDateTime(poll.ends_at)}` : '不限时'),
+  ].join(' · ');
+
+  const options = poll.options.map(o => {
+    const mine = poll.my_votes.includes(o.id);
+    if (!poll.show_results) {
+      return `<label class="poll-option">
+        <input type="${poll.multiple ? 'checkbox' : 'radio'}" name="poll-${postId}" value="${o.id}"${canVote ? '' : ' disabled'}>
+        <span>${HXZD.escapeHtml(o.text)}</span>
+      </label>`;
+    }
+    const percent = total ? Math.round(o.vote_count * 100 / total) : 0;
+    return `<div class="poll-option poll-result${mine ? ' mine' : ''}">
+      <div class="poll-result-bar" style="width:${percent}%"></div>
+      <span>${mine ? '✓ ' : ''}${HXZD.escapeHtml(o.text)}</span>
+      <span class="poll-result-count">${o.vote_count} 票 · ${percent}%</span>
+      ${o.voters?.length ? `<div class="poll-voters">${o.voters.map(v => HXZD.escapeHtml(v)).join('、')}</div>` : ''}
+    </div>`;
+  }).join('');
+
+  let actions = '';
+  if (canVote) {
+    actions = `<button class="sao-submit-btn btn-small" onclick="votePoll(${postId})">投 票</button>`;
+  } else if (voted && !poll.closed) {
+    actions = `<button class="sao-submit-btn btn-small btn-secondary" onclick="unvotePoll(${postId})">撤销投票</button>`;
+  } else if (!poll.show_results) {
+    actions = '<span class="poll-hint">登录后投票，投票后可查看结果</span>';
+  }
+
+  return `
+    <div class="poll-status">📊 ${status}</div>
+    <div class="poll-options">${options}</div>
+    ${actions ? `<div class="poll-actions">${actions}</div>` : ''}
+  `;
+}
+
+async function votePoll(postId) {
+  const ids = [...document.querySelectorAll(`#postPoll input[name="poll-${postId}"]:checked`)].map(i => Number(i.value));
+  if (!ids.length) { HXZD.toast('请选择投票选项'); return; }
+  await sendPollVote(postId, 'POST', { option_ids: ids });
+}
+
+async function unvotePoll(postId) {
+  await sendPollVote(postId, 'DELETE');
+}
+
+async function sendPollVote(postId, method, body) {
+  try {
+    const res = await HXZD.authFetch(`/forum/posts/${postId}/poll/vote`, { method, body });
+    const data = await res.json();
+    if (!res.ok) {
+      HXZD.toast(HXZD.errorText(data, '操作失败'));
+      return;
+    }
+    document.getElementById('postPoll').innerHTML = renderPoll(postId, data);
+  } catch (e) {
+    HXZD.toast('网络错误');
+  }
+}
+
+// 关注帖子后有新回复会收到通知；发帖与评论时自动关注
+async function toggleWatchPost(id) {
+  const btn = document.getElementById('watchPostBtn');
+  const watching = btn.dataset.watching === 'true';
+  try {
+    const res = await HXZD.authFetch(`/forum/posts/${id}/watch`, { method: watching ? 'DELETE' : 'POST' });
+    const data = await res.json();
+    if (!res.ok) {
+      HXZD.toast(HXZD.errorText(data, '操作失败'));
+      return;
+    }
+    btn.dataset.watching = data.watching;
+    btn.textContent = data.watching ? '★ 已关注' : '☆ 关注';
+  } catch (e) {
+    HXZD.toast('网络错误');
+  }
+}
+
+async function showReportForm(type, id) {
+  if (!reportReasons) {
+    try {
+      reportReasons = await (await fetch(HXZD.API + '/reports/reasons')).json();
+    } catch (e) {
+      HXZD.toast('加载失败');
+      return;
+    }
+  }
+  closeReportForm();
+  document.body.insertAdjacentHTML('beforeend', `
+    <div class="report-modal" id="reportModal">
+      <div class="report-dialog sao-panel">
+        <div class="sao-panel-header"><span class="sao-panel-diamond"></span><span>举报${type === 'post' ? '帖子' : '评论'}</span></div>
+        <div style="padding:16px">
+          <div class="sao-input-group"><label>原因</label>
+            <select id="reportReason" class="sao-select">${reportReasons.map(r => `<option value="${r.key}">${HXZD.escapeHtml(r.label)}</option>`).join('')}</select>
+          </div>
+          <div class="sao-input-group"><label>补充说明（可选）</label><textarea id="reportDetail" rows="3" maxlength="512"></textarea></div>
+          <div style="display:flex;gap:10px">
+            <button class="sao-submit-btn btn-small" onclick="submitReport('${type}', ${id})">提交</button>
+            <button class="sao-submit-btn btn-small btn-secondary" onclick="closeReportForm()">取消</button>
+          </div>
+        </div>
+      </div>
+    </div>`);
+}
+
+function closeReportForm() {
+  const modal = document.getElementById('reportModal');
+  if (modal) modal.remove();
+}
+
+async function submitReport(type, id) {
+  const res = await HXZD.authFetch('/reports', {
+    method: 'POST',
+    body: {
+      target_type: type,
+      target_id: id,
+      reason: document.getElementById('reportReason').value,
+      detail: document.getElementById('reportDetail').value.trim(),
+    },
+  });
+  const data = await res.json();
+  if (!res.ok) {
+    HXZD.toast(HXZD.errorText(data, '举报失败'));
+    return;
+  }
+  closeReportForm();
+  HXZD.toast(data.message);
+}
+
+// ===== 表情回应 =====
+function renderReactions(type, id, reactions) {
+  const key = `${type}-${id}`;
+  reactionState[key] = reactions || [];
+  return `<div class="reaction-bar" id="reactions-${key}">${reactionButtons(type, id)}</div>`;
+}
+
+function reactionButtons(type, id) {
+  const list = reactionState[`${type}-${id}`];
+  const emojis = [...reactionEmojis];
+  list.forEach(r => { if (!emojis.includes(r.emoji)) emojis.push(r.emoji); });
+  const loggedIn = HXZD.isLoggedIn();
+  return emojis.map((emoji, i) => {
+    const r = list.find(x => x.emoji === emoji);
+    if (!r && !loggedIn) return '';
+    return `<button class="reaction-btn ${r?.reacted ? 'active' : ''} ${r ? '' : 'empty'}"
+      ${loggedIn ? `onclick="toggleReaction('${type}', ${id}, ${i})"` : 'disabled'}>${HXZD.escapeHtml(emoji)}${r ? ` ${r.count}` : ''}</button>`;
+  }).join('');
+}
+
+async function toggleReaction(type, id, index) {
+  const key = `${type}-${id}`;
+  const list = reactionState[key];
+  const emojis = [...reactionEmojis];
+  list.forEach(r => { if (!emojis.includes(r.emoji)) emojis.push(r.emoji); });
+  const emoji = emojis[index];
+  const reacted = list.some(r => r.emoji === emoji && r.reacted);
+  const base = type === 'post' ? `/forum/posts/${id}/reactions` : `/forum/comments/${id}/reactions`;
+
+  try {
+    const res = reacted
+      ? await HXZD.authFetch(`${base}?emoji=${encodeURIComponent(emoji)}`, { method: 'DELETE' })
+      : await HXZD.authFetch(base, { method: 'POST', body: { emoji } });
+    const data = await res.json();
+    if (!res.ok) {
+      HXZD.toast(HXZD.errorText(data, '操作失败'));
+      return;
+    }
+    reactionState[key] = data.reactions || [];
+    document.getElementById(`reactions-${key}`).innerHTML = reactionButtons(type, id);
+  } catch (e) {
+    HXZD.toast('网络错误');
+  }
+}
+
+// 回复或引用的目标评论
+let commentTarget = null;
+
+function setCommentTarget(kind, id) {
+  commentTarget = { kind, id };
+  const username = document.querySelector(`#comment-${id} .comment-author-name`).textContent;
+  const el = document.getElementById('commentTarget');
+  el.innerHTML = `${kind === 'parent' ? '回复' : '引用'} @${HXZD.escapeHtml(username)} <button onclick="clearCommentTarget()">取消</button>`;
+  el.style.display = 'block';
+  document.getElementById('commentInput').focus();
+}
+
+function clearCommentTarget() {
+  commentTarget = null;
+  const el = document.getElementById('commentTarget');
+  if (el) el.style.display = 'none';
+}
+
 async function submitComment(postId) {
   const input = document.getElementById('commentInput');
   const content = input.value.trim();
   if (!content) return;
 
+  const body = { content };
+  if (commentTarget) body[commentTarget.kind === 'parent' ? 'parent_id' : 'quote_id'] = commentTarget.id;
+
   try {
     const res = await HXZD.authFetch(`/forum/posts/${postId}/comments`, {
       method: 'POST',
-      body: { content },
+      body,
     });
     if (res.ok) {
       input.value = '';
+      clearCommentTarget();
       viewPost(postId);
     } else {
       const data = await res.json();
-      HXZD.toast(data.error || '评论失败');
+      HXZD.toast(HXZD.errorText(data, '评论失败'));
     }
   } catch (e) {
     HXZD.toast('网络错误');
   }
 }
 
-async function deletePost(id) {
-  if (!confirm('确定要删除这篇帖子吗？')) return;
+// 删除确认；版主删除他人内容时可填写原因（会通知作者），取消返回 null
+function askDeleteReason(message, moderating) {
+  if (!moderating) return confirm(message) ? '' : null;
+  const reason = prompt(message + '\n删除原因（会通知作者，可留空）：', '');
+  return reason === null ? null : reason.trim();
+}
+
+async function deletePost(id, moderating) {
+  const reason = askDeleteReason('确定要删除这篇帖子吗？', moderating);
+  if (reason === null) return;
   try {
-    await HXZD.authFetch(`/forum/posts/${id}`, { method: 'DELETE' });
+    await HXZD.authFetch(`/forum/posts/${id}?reason=${encodeURIComponent(reason)}`, { method: 'DELETE' });
     HXZD.toast('已删除');
     backToList();
   } catch (e) {
@@ -200,20 +643,29 @@ async function deletePost(id) {
   }
 }
 
-async function deleteComment(commentId, postId) {
-  if (!confirm('确定删除此评论？')) return;
+async function deleteComment(commentId, postId, moderating) {
+  const reason = askDeleteReason('确定删除此评论？', moderating);
+  if (reason === null) return;
   try {
-    await HXZD.authFetch(`/forum/comments/${commentId}`, { method: 'DELETE' });
+    await HXZD.authFetch(`/forum/comments/${commentId}?reason=${encodeURIComponent(reason)}`, { method: 'DELETE' });
     viewPost(postId);
   } catch (e) {
     HXZD.toast('删除失败');
   }
 }
 
+// 发帖人机验证的结果收集函数
+let newPostChallenge = null;
+
+async function refreshNewPostChallenge() {
+  newPostChallenge = await HXZD.prepareChallenges('post', document.getElementById('newPostChallenge'));
+}
+
 function showNewPostForm() {
   document.getElementById('forumListView').style.display = 'none';
   document.getElementById('forumPostView').style.display = 'none';
   document.getElementById('newPostFormView').style.display = 'block';
+  refreshNewPostChallenge();
 }
 
 // ===== 编辑帖子 =====
@@ -231,11 +683,13 @@ async function submitEditPost(id) {
   const title = document.getElementById('editPostTitle').value.trim();
   const content = document.getElementById('editPostContent').value.trim();
   const category = document.getElementById('editPostCategory').value;
+  const reason = document.getElementById('editPostReason').value.trim();
+  const tags = splitTags(document.getElementById('editPostTags').value);
   if (!title || !content) { HXZD.toast('标题和内容不能为空'); return; }
   try {
     const res = await HXZD.authFetch(`/forum/posts/${id}`, {
       method: 'PUT',
-      body: { title, content, category },
+      body: { title, content, category, tags, reason },
     });
     if (res.ok) {
       HXZD.toast('修改成功');
@@ -249,25 +703,111 @@ async function submitEditPost(id) {
   }
 }
 
+// ===== 编辑历史 =====
+async function showRevisions(id) {
+  const el = document.getElementById('postRevisions');
+  if (el.style.display !== 'none') {
+    el.style.display = 'none';
+    return;
+  }
+  el.style.display = 'block';
+  loadRevisions(id, 1);
+}
+
+async function loadRevisions(id, page) {
+  const el = document.getElementById('postRevisions');
+  el.innerHTML = '<div class="loading-placeholder">加载中...</div>';
+  try {
+    const res = await HXZD.authFetch(`/forum/posts/${id}/revisions?page=${page}&size=10`);
+    const data = await res.json();
+    if (!res.ok) {
+      el.innerHTML = `<div class="loading-placeholder">${HXZD.escapeHtml(data.error || '加载失败')}</div>`;
+      return;
+    }
+    const canRestore = HXZD.can('forum.moderate');
+    el.innerHTML = `<div class="sao-panel-header"><span class="sao-panel-diamond"></span><span>编辑历史</span></div>` +
+      data.revisions.map((r, i) => `
+        <div class="revision-item">
+          <div class="revision-meta">
+            <span>v${r.version}</span>
+            <span>${HXZD.escapeHtml(r.editor?.username || '—')}</span>
+            <span>${HXZD.formatDateTime(r.created_at)}</span>
+            ${r.reason ? `<span>${HXZD.escapeHtml(r.reason)}</span>` : ''}
+            ${canRestore && (page > 1 || i > 0) ? `<button onclick="restoreRevision(${id}, ${r.id}, ${r.version})">恢复此版本</button>` : ''}
+          </div>
+          ${r.previous_title ? `<div class="revision-title">标题：<del>${HXZD.escapeHtml(r.previous_title)}</del> → ${HXZD.escapeHtml(r.title)}</div>` : ''}
+          ${r.diff ? `<pre class="revision-diff">${renderDiff(r.diff)}</pre>` : (r.version === 1 ? '<div class="revision-title">原始版本</div>' : '')}
+        </div>`).join('');
+
+    // 分页
+    const totalPages = Math.ceil(data.total / data.size);
+    if (totalPages > 1) {
+      let html = '';
+      for (let i = 1; i <= totalPages; i++) {
+        html += `<button class="${i === page ? 'active' : ''}" onclick="loadRevisions(${id}, ${i})">${i}</button>`;
+      }
+      el.insertAdjacentHTML('beforeend', `<div class="forum-pagination">${html}</div>`);
+    }
+  } catch (e) {
+    el.innerHTML = '<div class="loading-placeholder">加载失败</div>';
+  }
+}
+
+function renderDiff(diff) {
+  return diff.split('\n').filter(Boolean).map(line => {
+    const cls = line.startsWith('@@') ? 'hunk'
+      : line.startsWith('+++') || line.startsWith('---') ? 'file'
+      : line[0] === '+' ? 'add' : line[0] === '-' ? 'del' : '';
+    return `<span class="diff-line ${cls}">${HXZD.escapeHtml(line)}</span>`;
+  }).join('\n');
+}
+
+async function restoreRevision(postId, revisionId, version) {
+  if (!confirm(`确定将帖子恢复到第 ${version} 版？`)) return;
+  const res = await HXZD.authFetch(`/forum/posts/${postId}/revisions/${revisionId}/restore`, { method: 'POST' });
+  const data = await res.json();
+  if (!res.ok) {
+    HXZD.toast(data.error || '恢复失败');
+    return;
+  }
+  HXZD.toast('已恢复');
+  viewPost(postId);
+}
+
 async function submitNewPost(e) {
   e.preventDefault();
   const form = e.target;
+  let poll;
+  if (form.hasPoll.checked) {
+    poll = {
+      options: form.pollOptions.value.split('\n').map(s => s.trim()).filter(Boolean),
+      multiple: form.pollMultiple.checked,
+      anonymous: form.pollAnonymous.checked,
+      ends_at: form.pollEndsAt.value ? new Date(form.pollEndsAt.value).toISOString() : null,
+    };
+  }
   try {
+    const challenge = newPostChallenge ? await newPostChallenge() : {};
     const res = await HXZD.authFetch('/forum/posts', {
       method: 'POST',
       body: {
         title: form.title.value,
         content: form.content.value,
         category: form.category.value,
+        tags: splitTags(form.tags.value),
+        poll,
+        challenge,
       },
     });
     if (res.ok) {
       HXZD.toast('发布成功！');
       form.reset();
+      document.getElementById('newPostPoll').style.display = 'none';
       backToList();
     } else {
       const data = await res.json();
-      HXZD.toast(data.error || '发布失败');
+      HXZD.toast(HXZD.errorText(data, '发布失败'));
+      refreshNewPostChallenge();
     }
   } catch (e) {
     HXZD.toast('网络错误');